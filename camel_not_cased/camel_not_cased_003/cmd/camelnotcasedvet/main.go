@@ -0,0 +1,17 @@
+// Command camelnotcasedvet runs the camelnotcased naming check as a
+// standalone linter or as a go vet tool:
+//
+//	camelnotcasedvet ./...
+//	camelnotcasedvet -fix ./...
+//	go vet -vettool=$(which camelnotcasedvet) ./...
+package main
+
+import (
+	"camel_not_cased_003/rename"
+
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(rename.Analyzer)
+}
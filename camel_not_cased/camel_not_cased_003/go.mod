@@ -2,7 +2,10 @@ module camel_not_cased_003
 
 go 1.24.5
 
-require golang.org/x/tools v0.36.0
+require (
+	golang.org/x/tools v0.36.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	golang.org/x/mod v0.27.0 // indirect
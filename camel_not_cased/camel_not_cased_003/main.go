@@ -1,282 +1,161 @@
 package main
 
 import (
-	"bytes"
+	"errors"
 	"flag"
 	"fmt"
-	"go/ast"
-	"go/printer"
 	"go/token"
-	"go/types"
 	"os"
-	"regexp"
-	"strconv"
 	"strings"
 
-	"golang.org/x/tools/go/packages"
+	"camel_not_cased_003/rename"
 )
 
 var (
 	keepExported = flag.Bool("keep-exported", false, "do not rename exported identifiers")
 	writeFiles   = flag.Bool("w", false, "write the results to the source files instead of stdout")
-	editComments = flag.Bool("comments", true, "also rewrite comments that mention renamed identifiers")
-	editStrings  = flag.Bool("strings", false, "also rewrite string literals that mention renamed identifiers (careful)")
+	listFiles    = flag.Bool("l", false, "list files whose content would change")
+	showDiff     = flag.Bool("d", false, "display a unified diff of the changes instead of the rewritten files")
+	editComments = flag.Bool("comments", true, "also rewrite mentions of renamed identifiers in comments, where they are in scope")
+	editStrings  = flag.Bool("strings", true, "also rewrite mentions of renamed identifiers in string literals marked with //"+rename.StringsDirective)
+	moduleMode   = flag.Bool("module", false, "treat arguments as directories and load every module (go.mod) below them, so that\nrenamed exported identifiers are updated in all importing packages")
+	variants     = flag.Bool("variants", false, "also load packages for every GOOS/GOARCH and build tag combination their files declare,\nso that files for other platforms are renamed too")
+	fromFlag     = flag.String("from", "", "rename only this identifier, gorename style: \"pkg/path\".Name or \"pkg/path\".Type.Member")
+	toFlag       = flag.String("to", "", "new name for the identifier given by -from; without -from, the target style, as in -to=mixedcaps\n(Go MixedCaps with initialisms such as URL, ID and HTTP, keeping exported names exported)")
+	verify       = flag.Bool("verify", false, "type-check the renamed packages in memory and write nothing unless they still build")
+	gofmtOutput  = flag.Bool("fmt", false, "gofmt the rewritten files (by default only the renamed tokens change)")
+	fieldTags    = flag.String("field-tags", "add", "how to protect the wire names of renamed struct fields: add (insert a tag with the old name),\nskip (do not rename fields without an explicit name) or off")
+	tagKeysFlag  = flag.String("tag-keys", "", "comma-separated struct tag keys to protect (default: detected from imported encoding packages)")
+	tagsReached  = flag.Bool("field-tags-reached", false, "with detected tag keys, add tags only to fields of structs that reach an encoder call in the loaded\npackages, and warn about the rest (default: protect those with every detected key)")
+	configFlag   = flag.String("config", "", "use this config file instead of looking for "+rename.ConfigName+" in each package directory and its parents")
+	force        = flag.Bool("force", false, "rename even if conflicting or shadowing declarations are found")
+	styleFlag    = flag.String("style", "snake_case", "target naming style: snake_case, camelCase, PascalCase, SCREAMING_SNAKE, Capitalized_snake, mixedcaps or keep")
+	rulesFlag    = flag.String("rules", "", "per-kind styles, e.g. const=SCREAMING_SNAKE,exported-func=Capitalized_snake,local=snake_case\n(kinds: const, var, local, func, method, type, field, label; optionally prefixed with exported- or unexported-)")
 )
 
-// camelToSnake converts camelCase/PascalCase to snake_case
-func camelToSnake(s string) string {
-	if s == "" || s == "_" || len(s) == 1 {
-		return s
-	}
-	re1 := regexp.MustCompile(`([a-z0-9])([A-Z])`)
-	s = re1.ReplaceAllString(s, `${1}_${2}`)
-	re2 := regexp.MustCompile(`([A-Z])([A-Z][a-z])`)
-	s = re2.ReplaceAllString(s, `${1}_${2}`)
-	return strings.ToLower(s)
-}
-
-func isValidIdent(s string) bool {
-	if s == "" {
-		return false
-	}
-	if s[0] != '_' && (s[0] < 'a' || s[0] > 'z') {
-		return false
-	}
-	for i := 1; i < len(s); i++ {
-		c := s[i]
-		if c == '_' || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
-			continue
-		}
-		return false
-	}
-	return true
-}
+// exitPending is the exit status used with -l or -d (and without -w) when
+// some file would change, so the tool can gate CI.
+const exitPending = 3
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "usage: camelnotcased [flags] <packages-or-files>\n")
+		fmt.Fprintf(os.Stderr, "usage: camelnotcased [flags] <packages-or-files>\n       camelnotcased -module [flags] [dirs]\n")
 		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nWith -l or -d and without -w, the exit status is %d if any file would change.\n", exitPending)
 	}
+	flag.BoolVar(showDiff, "diff", false, "same as -d")
 	flag.Parse()
-	if flag.NArg() == 0 {
+	if flag.NArg() == 0 && !*moduleMode {
 		flag.Usage()
 		os.Exit(2)
 	}
-
-	cfg := &packages.Config{
-		Mode: packages.NeedName |
-			packages.NeedFiles |
-			packages.NeedCompiledGoFiles |
-			packages.NeedSyntax |
-			packages.NeedTypes |
-			packages.NeedTypesInfo |
-			packages.NeedTypesSizes,
+	args := flag.Args()
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	opts := &rename.Options{
+		Rules:            *rulesFlag,
+		Config:           *configFlag,
+		KeepExported:     *keepExported,
+		From:             *fromFlag,
+		To:               *toFlag,
+		Module:           *moduleMode,
+		Variants:         *variants,
+		FieldTags:        *fieldTags,
+		FieldTagsReached: *tagsReached,
+		Comments:         *editComments,
+		Strings:          *editStrings,
+		Format:           *gofmtOutput,
+		Force:            *force,
+		Log:              os.Stderr,
+	}
+	// -style overrides the config file only when given explicitly
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "style" {
+			opts.Style = *styleFlag
+		}
+	})
+	if *tagKeysFlag != "" {
+		opts.TagKeys = strings.Split(*tagKeysFlag, ",")
+	}
+	if err := opts.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
 	}
 
-	pkgs, err := packages.Load(cfg, flag.Args()...)
+	fset := token.NewFileSet()
+	pkgs, err := rename.Load(fset, args, opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "load: %v\n", err)
 		os.Exit(1)
 	}
-	if packages.PrintErrors(pkgs) > 0 {
+
+	plan, err := rename.PlanRenames(pkgs, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
-
-	for _, pkg := range pkgs {
-		if err := renamePkg(pkg); err != nil {
-			fmt.Fprintf(os.Stderr, "rename: %v\n", err)
-			os.Exit(1)
-		}
+	for _, w := range plan.Warnings {
+		fmt.Fprintln(os.Stderr, w)
 	}
-}
-
-func renamePkg(pkg *packages.Package) error {
-	fset := pkg.Fset
-	info := pkg.TypesInfo
-	thisPkg := pkg.Types
-
-	// Decide new names: map each *types.Object -> new name
-	renames := map[types.Object]string{}
-	// Also keep a simple old->new text map for comments/strings
-	oldToNew := map[string]string{}
-
-	consider := func(obj types.Object) {
-		if obj == nil {
-			return
-		}
-		// Only objects that belong to *this* package
-		if obj.Pkg() == nil || obj.Pkg() != thisPkg {
-			return
-		}
-		// Optionally skip exported
-		if *keepExported && obj.Exported() {
-			return
-		}
-		// Skip package names
-		if _, isPkgName := obj.(*types.PkgName); isPkgName {
-			return
-		}
-		old := obj.Name()
-		newName := camelToSnake(old)
-		if newName == old || !isValidIdent(newName) {
-			return
-		}
-		renames[obj] = newName
-		oldToNew[old] = newName
+	// Refuse to write code that no longer compiles or means something else
+	for _, c := range plan.Conflicts {
+		fmt.Fprintln(os.Stderr, c)
 	}
 
-	// Collect candidates from Defs (declarations)
-	for ident, obj := range info.Defs {
-		if ident == nil || obj == nil {
-			continue
-		}
-		if ident.Name == "_" {
-			continue
-		}
-		consider(obj)
-	}
-	// Also consider objects that appear only in Uses
-	for _, obj := range info.Uses {
-		consider(obj)
+	report, err := rename.Apply(plan)
+	if errors.Is(err, rename.ErrConflicts) {
+		fmt.Fprintf(os.Stderr, "%d conflict(s) found; nothing written (use -force to rename anyway)\n", len(plan.Conflicts))
+		os.Exit(1)
 	}
-	// And the selected objects from selectors (fields/methods)
-	for _, sel := range info.Selections {
-		if sel == nil {
-			continue
-		}
-		consider(sel.Obj())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rename: %v\n", err)
+		os.Exit(1)
 	}
 
-	// Nothing to do?
-	if len(renames) == 0 {
-		// Still print or write original files for consistency? We'll just no-op.
-		if !*writeFiles {
-			for i, file := range pkg.Syntax {
-				var out bytes.Buffer
-				cfg := &printer.Config{Mode: printer.TabIndent | printer.UseSpaces, Tabwidth: 8}
-				if err := cfg.Fprint(&out, fset, file); err != nil {
-					return fmt.Errorf("print %s: %w", pkg.CompiledGoFiles[i], err)
-				}
-				os.Stdout.Write(out.Bytes())
-				fmt.Println()
-			}
+	if *verify {
+		if err := rename.Verify(args, plan, report); err != nil {
+			fmt.Fprintf(os.Stderr, "verify: the renamed code does not type-check; nothing written:\n%v\n", err)
+			os.Exit(1)
 		}
-		return nil
 	}
 
-	// Prebuild word-boundary regexes for comment/string rewriting
-	var wordRegex map[string]*regexp.Regexp
-	if *editComments || *editStrings {
-		wordRegex = make(map[string]*regexp.Regexp, len(oldToNew))
-		for old := range oldToNew {
-			wordRegex[old] = regexp.MustCompile(`\b` + regexp.QuoteMeta(old) + `\b`)
-		}
+	// Text outside the code is the easiest to get wrong; list every change
+	for _, s := range report.Substitutions {
+		fmt.Fprintln(os.Stderr, s)
 	}
 
-	for i, file := range pkg.Syntax {
-		// 1) Rename identifiers & selector .Sel where the selected object is ours
-		ast.Inspect(file, func(n ast.Node) bool {
-			switch n := n.(type) {
-			case *ast.Ident:
-				if n.Name == "_" {
-					return true
-				}
-				if obj := info.ObjectOf(n); obj != nil {
-					if newName, ok := renames[obj]; ok {
-						n.Name = newName
-					}
-				}
-			case *ast.SelectorExpr:
-				if sel := info.Selections[n]; sel != nil {
-					if newName, ok := renames[sel.Obj()]; ok {
-						n.Sel.Name = newName
-					}
-				}
-			}
-			return true
-		})
-
-		// 2) Optionally rewrite comments
-		if *editComments {
-			rewriteComments(file, oldToNew, wordRegex)
-		}
-
-		// 3) Optionally rewrite string literals
-		if *editStrings {
-			rewriteStrings(file, oldToNew, wordRegex)
-		}
-
-		// 4) Output
-		var out bytes.Buffer
-		cfg := &printer.Config{Mode: printer.TabIndent | printer.UseSpaces, Tabwidth: 8}
-		if err := cfg.Fprint(&out, fset, file); err != nil {
-			return fmt.Errorf("print %s: %w", pkg.CompiledGoFiles[i], err)
-		}
-
-		if *writeFiles {
-			if err := os.WriteFile(pkg.CompiledGoFiles[i], out.Bytes(), 0o666); err != nil {
-				return fmt.Errorf("write %s: %w", pkg.CompiledGoFiles[i], err)
-			}
-		} else {
-			os.Stdout.Write(out.Bytes())
-			fmt.Println()
+	pending := false
+	for _, c := range report.Changes {
+		if err := emit(c); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
 		}
+		pending = pending || c.Changed()
+	}
+	if pending && !*writeFiles && (*listFiles || *showDiff) {
+		os.Exit(exitPending)
 	}
-
-	return nil
 }
 
-func rewriteComments(file *ast.File, oldToNew map[string]string, regs map[string]*regexp.Regexp) {
-	if file == nil || file.Comments == nil || len(oldToNew) == 0 {
-		return
+// emit reports or writes c according to the output flags.
+func emit(c rename.FileChange) error {
+	changed := c.Changed()
+	if changed && *listFiles {
+		fmt.Println(c.Filename)
+	}
+	if changed && *showDiff {
+		os.Stdout.WriteString(c.Diff())
 	}
-	for _, cg := range file.Comments {
-		for _, c := range cg.List {
-			text := c.Text
-			for old, re := range regs {
-				text = re.ReplaceAllString(text, oldToNew[old])
-			}
-			c.Text = text
+	if changed && *writeFiles {
+		if err := c.Write(); err != nil {
+			return fmt.Errorf("write %s: %w", c.Filename, err)
 		}
 	}
-}
-
-func rewriteStrings(file *ast.File, oldToNew map[string]string, regs map[string]*regexp.Regexp) {
-	if file == nil || len(oldToNew) == 0 {
-		return
+	if !*listFiles && !*showDiff && !*writeFiles {
+		os.Stdout.Write(c.Out)
+		fmt.Println()
 	}
-	ast.Inspect(file, func(n ast.Node) bool {
-		lit, ok := n.(*ast.BasicLit)
-		if !ok || lit.Kind != token.STRING {
-			return true
-		}
-		val := lit.Value
-		if len(val) < 2 {
-			return true
-		}
-		quote := val[0]
-		switch quote {
-		case '"':
-			// interpreted string
-			unquoted, err := strconv.Unquote(val)
-			if err != nil {
-				return true
-			}
-			for old, re := range regs {
-				unquoted = re.ReplaceAllString(unquoted, oldToNew[old])
-			}
-			lit.Value = strconv.Quote(unquoted)
-		case '`':
-			// raw string
-			content := val[1 : len(val)-1]
-			for old, re := range regs {
-				content = re.ReplaceAllString(content, oldToNew[old])
-			}
-			// Caution: if content contains backticks, we could break; assume rare for identifier words.
-			lit.Value = "`" + content + "`"
-		default:
-			// unknown quote style, skip
-		}
-		return true
-	})
-}
\ No newline at end of file
+	return nil
+}
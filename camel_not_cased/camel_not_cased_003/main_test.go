@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files in testdata")
+
+// TestMain runs the command itself when the tests start it as a child
+// process, so that its output and exit status can be checked.
+func TestMain(m *testing.M) {
+	if os.Getenv("CAMELNOTCASED_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// TestOutputFlags runs the command with -d and with -l on a copy of the
+// mixedcaps case of the rename tests, checks what it prints against
+// testdata/mixedcaps.d.golden and testdata/mixedcaps.l.golden, with the
+// directory of the copy left out, and checks that the copy is unchanged.
+func TestOutputFlags(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join("rename", "testdata", "mixedcaps")
+	for _, name := range []string{"go.mod", "a.go"} {
+		data, err := os.ReadFile(filepath.Join(src, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := readTree(t, dir)
+
+	for _, output := range []string{"d", "l"} {
+		t.Run(output, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-to=mixedcaps", "-"+output, ".")
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(), "CAMELNOTCASED_TEST_MAIN=1")
+			var stdout, stderr bytes.Buffer
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			err := cmd.Run()
+			if exit := (*exec.ExitError)(nil); !errors.As(err, &exit) || exit.ExitCode() != exitPending {
+				t.Errorf("exit status: got %v, want %d; stderr:\n%s", err, exitPending, stderr.Bytes())
+			}
+
+			// The copy's path may differ from dir by symbolic links.
+			real, err := filepath.EvalSymlinks(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := strings.ReplaceAll(stdout.String(), real+string(filepath.Separator), "")
+			got = strings.ReplaceAll(got, dir+string(filepath.Separator), "")
+			checkGolden(t, filepath.Join("testdata", "mixedcaps."+output+".golden"), []byte(got))
+
+			if after := readTree(t, dir); !maps.Equal(before, after) {
+				t.Errorf("-%s changed the files in %s", output, dir)
+			}
+		})
+	}
+}
+
+// readTree returns the content of every file in dir by name.
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[entry.Name()] = string(data)
+	}
+	return files
+}
+
+func checkGolden(t *testing.T, golden string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(golden, got, 0o666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\ngot:\n%s\nwant:\n%s", filepath.Base(golden), got, want)
+	}
+}
@@ -0,0 +1,144 @@
+package rename
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// Analyzer reports identifiers that do not follow the naming convention,
+// with a suggested fix that renames the declaration and every use in the
+// package. Editors can offer the fix as a quick-fix, and go vet -vettool
+// can enforce the convention.
+//
+// It honours .camelnotcased.yaml like the command does. Renames that would
+// introduce a conflict are reported without a fix, and so are exported
+// identifiers: the analyzer cannot see the packages that import them, so
+// the command with -module has to rename them everywhere.
+var Analyzer = &analysis.Analyzer{
+	Name: "camelnotcased",
+	Doc: `check that identifiers follow a naming convention
+
+Reports every identifier whose name does not follow the configured style
+(snake_case by default), as set by the flags or by the nearest
+.camelnotcased.yaml, and suggests renaming it.`,
+	Run: runAnalyzer,
+}
+
+// analyzerOpts are set through the analyzer's flags.
+var analyzerOpts Options
+
+func init() {
+	Analyzer.Flags.StringVar(&analyzerOpts.Style, "style", "", "naming style (default: from "+ConfigName+", else snake_case)")
+	Analyzer.Flags.StringVar(&analyzerOpts.Rules, "rules", "", "per-kind styles, e.g. const=SCREAMING_SNAKE,local=snake_case")
+	Analyzer.Flags.StringVar(&analyzerOpts.Config, "config", "", "config file to use instead of looking for "+ConfigName)
+	Analyzer.Flags.BoolVar(&analyzerOpts.KeepExported, "keep-exported", false, "do not report exported identifiers")
+	Analyzer.Flags.StringVar(&analyzerOpts.FieldTags, "field-tags", "add", "how to protect the wire names of renamed struct fields: add, skip or off")
+	Analyzer.Flags.BoolVar(&analyzerOpts.FieldTagsReached, "field-tags-reached", false, "add tags only to fields of structs that reach an encoder call in the package")
+}
+
+func runAnalyzer(pass *analysis.Pass) (any, error) {
+	// Plan as the command would, over a package made from the pass.
+	pkg := &packages.Package{
+		ID:        pass.Pkg.Path(),
+		Name:      pass.Pkg.Name(),
+		PkgPath:   pass.Pkg.Path(),
+		Fset:      pass.Fset,
+		Syntax:    pass.Files,
+		Types:     pass.Pkg,
+		TypesInfo: pass.TypesInfo,
+		Imports:   map[string]*packages.Package{},
+	}
+	// Direct imports are enough to find the encoding packages in use.
+	for _, imp := range pass.Pkg.Imports() {
+		pkg.Imports[imp.Path()] = &packages.Package{ID: imp.Path(), PkgPath: imp.Path(), Name: imp.Name()}
+	}
+	tokFiles := map[string]*token.File{}
+	for _, file := range pass.Files {
+		tokFile := pass.Fset.File(file.Pos())
+		tokFiles[tokFile.Name()] = tokFile
+		pkg.GoFiles = append(pkg.GoFiles, tokFile.Name())
+	}
+	opts := analyzerOpts
+	plan, err := PlanRenames([]*packages.Package{pkg}, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Gather the edits of each rename: the renamed identifiers first, then
+	// the edits it requires, such as struct tags.
+	edits := map[objectKey][]analysis.TextEdit{}
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident)
+			if !ok || id.Name == "_" {
+				return true
+			}
+			obj := pass.TypesInfo.ObjectOf(id)
+			if obj == nil {
+				return true
+			}
+			key, ok := plan.renameKey(obj)
+			if !ok {
+				return true
+			}
+			if r, ok := plan.names[key]; ok && r.New != id.Name {
+				edits[key] = append(edits[key], analysis.TextEdit{Pos: id.Pos(), End: id.End(), NewText: []byte(r.New)})
+			}
+			return true
+		})
+	}
+	unfixable := map[objectKey]bool{}
+	for filename, fileEdits := range plan.edits {
+		tokFile := tokFiles[filename]
+		for _, e := range fileEdits {
+			if tokFile == nil {
+				// An edit in a source file behind a cgo-generated one.
+				unfixable[e.owner] = true
+				continue
+			}
+			edits[e.owner] = append(edits[e.owner], analysis.TextEdit{
+				Pos:     tokFile.Pos(e.start),
+				End:     tokFile.Pos(e.end),
+				NewText: []byte(e.text),
+			})
+		}
+	}
+
+	keys := make([]objectKey, 0, len(plan.names))
+	for key := range plan.names {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return positionLess(plan.names[keys[i]].Pos, plan.names[keys[j]].Pos) })
+	for _, key := range keys {
+		r := plan.names[key]
+		tokFile := tokFiles[r.Pos.Filename]
+		if r.Old == r.New || tokFile == nil {
+			continue
+		}
+		pos := tokFile.Pos(r.Pos.Offset)
+		diag := analysis.Diagnostic{
+			Pos:     pos,
+			End:     pos + token.Pos(len(r.Old)),
+			Message: fmt.Sprintf("%s %s should be named %s", r.Kind, r.Old, r.New),
+		}
+		if conflicts := plan.conflictsOf[key]; len(conflicts) > 0 {
+			// No fix: applying it would break the code.
+			diag.Message += fmt.Sprintf(" (not fixable: %s)", conflicts[0].Message)
+		} else if token.IsExported(r.Old) {
+			// Importers would keep the old name.
+			diag.Message += " (not fixable: exported; use -module)"
+		} else if !unfixable[key] {
+			diag.SuggestedFixes = []analysis.SuggestedFix{{
+				Message:   fmt.Sprintf("Rename %s to %s", r.Old, r.New),
+				TextEdits: edits[key],
+			}}
+		}
+		pass.Report(diag)
+	}
+	return nil, nil
+}
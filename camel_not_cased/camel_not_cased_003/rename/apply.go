@@ -0,0 +1,160 @@
+package rename
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Apply rewrites the files of the planned packages. Only the renamed tokens
+// are replaced; the rest of each file is kept byte for byte. Nothing is
+// written to disk: the new contents are returned in the report.
+func Apply(plan *Plan) (*Report, error) {
+	if len(plan.Conflicts) > 0 && !plan.opts.Force {
+		return nil, ErrConflicts
+	}
+	report := &Report{
+		Renames:   plan.Renames(),
+		Conflicts: plan.Conflicts,
+		Warnings:  plan.Warnings,
+	}
+	// Test variants of a package share its files; rewrite each file once.
+	done := map[string]bool{}
+	for _, pkg := range plan.pkgs {
+		changes, subs, err := renamePkg(plan, pkg, done)
+		if err != nil {
+			return nil, err
+		}
+		report.Changes = append(report.Changes, changes...)
+		report.Substitutions = append(report.Substitutions, subs...)
+	}
+	sort.SliceStable(report.Substitutions, func(i, j int) bool {
+		return positionLess(report.Substitutions[i].Pos, report.Substitutions[j].Pos)
+	})
+	return report, nil
+}
+
+// renamePkg applies plan to the files of pkg that are not yet in done,
+// including references to objects declared in other packages, and returns
+// the changes along with the substitutions made in comments and strings.
+func renamePkg(plan *Plan, pkg *packages.Package, done map[string]bool) ([]FileChange, []Substitution, error) {
+	fset := pkg.Fset
+	info := pkg.TypesInfo
+
+	type fileEdits struct {
+		file     *ast.File
+		tokFile  *token.File
+		filename string // the source file, which cgo may have rewritten
+		cgo      bool
+		src      []byte
+		edits    []textEdit
+	}
+	var files []*fileEdits
+	for _, file := range pkg.Syntax {
+		tokFile := fset.File(file.Pos())
+		filename := tokFile.Name()
+		cgo := !isSourceFile(pkg, filename)
+		if cgo {
+			// cgo generated this file from a source file, to which its
+			// //line directives lead back; files it made from scratch,
+			// such as _cgo_gotypes.go, lead nowhere.
+			filename = fset.Position(file.Package).Filename
+			if !isSourceFile(pkg, filename) {
+				continue
+			}
+		}
+		if done[filename] {
+			continue
+		}
+		done[filename] = true
+		sf, err := plan.source(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		src := sf.src
+		fe := &fileEdits{file: file, tokFile: tokFile, filename: filename, cgo: cgo, src: src}
+		for _, e := range plan.edits[filename] {
+			if _, ok := plan.names[e.owner]; ok {
+				fe.edits = append(fe.edits, e.textEdit)
+			}
+		}
+		files = append(files, fe)
+
+		// Rename identifiers; selector .Sel identifiers are recorded in
+		// Uses, so they are covered here along with plain identifiers
+		var err2 error
+		ast.Inspect(file, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident)
+			if !ok || id.Name == "_" || err2 != nil {
+				return true
+			}
+			obj := info.ObjectOf(id)
+			if obj == nil {
+				return true
+			}
+			newName, ok := plan.newName(obj)
+			if !ok || newName == id.Name {
+				return true
+			}
+			start := tokFile.Offset(id.Pos())
+			if cgo {
+				name, offset, ok := plan.cgoOffset(id.Pos())
+				if !ok || name != filename {
+					return true // code cgo added
+				}
+				start = offset
+			}
+			end := start + len(id.Name)
+			if end > len(src) || string(src[start:end]) != id.Name {
+				if cgo {
+					plan.opts.logf("%s: cannot find %s in the source; not renamed here", fset.Position(id.Pos()), id.Name)
+					return true
+				}
+				err2 = fmt.Errorf("%s: source does not match the parsed file; was it modified?", fset.Position(id.Pos()))
+				return false
+			}
+			fe.edits = append(fe.edits, textEdit{start: start, end: end, text: newName})
+			return true
+		})
+		if err2 != nil {
+			return nil, nil, err2
+		}
+	}
+
+	var changes []FileChange
+	var subs []Substitution
+	for _, fe := range files {
+		// Comments and strings are found by offset in the parsed file,
+		// which for cgo is not the source; only identifiers are renamed.
+		text := newTextRenamer(plan, pkg, fe.tokFile, fe.file)
+		if plan.opts.Comments && !fe.cgo {
+			edits, s := text.commentEdits()
+			fe.edits = append(fe.edits, edits...)
+			subs = append(subs, s...)
+		}
+		if plan.opts.Strings && !fe.cgo {
+			edits, s := text.stringEdits()
+			fe.edits = append(fe.edits, edits...)
+			subs = append(subs, s...)
+		}
+
+		out, err := applyEdits(fe.src, fe.edits)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", fe.filename, err)
+		}
+		if plan.opts.Format {
+			formatted, err := format.Source(out)
+			if err != nil {
+				return nil, nil, fmt.Errorf("gofmt %s: %v", fe.filename, err)
+			}
+			out = formatted
+		}
+		changes = append(changes, FileChange{Filename: fe.filename, Src: fe.src, Out: out})
+	}
+
+	return changes, subs, nil
+}
@@ -0,0 +1,195 @@
+package rename
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigName is the file looked for in each package directory and its
+// parents.
+const ConfigName = ".camelnotcased.yaml"
+
+// config is the content of a .camelnotcased.yaml file:
+//
+//	style: snake_case
+//	rules:
+//	  const: SCREAMING_SNAKE
+//	  exported-func: Capitalized_snake
+//	acronyms: [IPv6, OAuth2, HTTP]
+//	overrides:
+//	  fooBar: foo_bar_baz
+//	ignore:
+//	  identifiers: ["Test*", "Benchmark*"]
+//	  files: ["*_gen.go", "zz_*.go"]
+//	generated: skip
+//
+// Declarations in ignored or generated files are never renamed, but
+// references in them to renamed objects are still updated so the code keeps
+// compiling.
+type config struct {
+	Style     string            `yaml:"style"`
+	Rules     map[string]string `yaml:"rules"`
+	Acronyms  []string          `yaml:"acronyms"`
+	Overrides map[string]string `yaml:"overrides"`
+	Ignore    struct {
+		Identifiers []string `yaml:"identifiers"`
+		Files       []string `yaml:"files"`
+	} `yaml:"ignore"`
+	Generated string `yaml:"generated"` // "skip" (default) or "rename"
+}
+
+// settings are the naming settings in effect for one package: its config
+// file merged with the Options, which take precedence.
+type settings struct {
+	rules         *namingRules
+	overrides     map[string]string
+	ignoreIdents  []string
+	ignoreFiles   []string
+	skipGenerated bool
+	dir           string // directory of the config file, if any
+}
+
+// settingsFor returns the settings for pkg, from Options.Config if set, or
+// else from the nearest .camelnotcased.yaml in the package directory or above.
+func (p *Plan) settingsFor(pkg *packages.Package) (*settings, error) {
+	if p.opts.Config != "" {
+		return p.loadSettings(p.opts.Config)
+	}
+	if len(pkg.GoFiles) == 0 {
+		return p.loadSettings("")
+	}
+	for dir := filepath.Dir(pkg.GoFiles[0]); ; {
+		file := filepath.Join(dir, ConfigName)
+		if _, err := os.Stat(file); err == nil {
+			return p.loadSettings(file)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return p.loadSettings("")
+		}
+		dir = parent
+	}
+}
+
+// loadSettings reads the config file (none if file is "") and applies the
+// options on top of it.
+func (p *Plan) loadSettings(file string) (*settings, error) {
+	if s, ok := p.settings[file]; ok {
+		return s, nil
+	}
+
+	var cfg config
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+	}
+
+	styleName := string(styleSnake)
+	if name := p.opts.style(); name != "" {
+		styleName = name
+	} else if cfg.Style != "" {
+		styleName = cfg.Style
+	}
+	style, err := parseStyle(styleName)
+	if err != nil {
+		return nil, fmt.Errorf("%s: style: %v", file, err)
+	}
+
+	// Rules from the file first, so that -rules entries override them.
+	kinds := make([]string, 0, len(cfg.Rules))
+	for kind := range cfg.Rules {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	var specs []string
+	for _, kind := range kinds {
+		specs = append(specs, kind+"="+cfg.Rules[kind])
+	}
+	if p.opts.Rules != "" {
+		specs = append(specs, p.opts.Rules)
+	}
+	rules, err := parseRules(style, strings.Join(specs, ","))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	rules.acronyms = cfg.Acronyms
+
+	for _, pattern := range append(append([]string{}, cfg.Ignore.Identifiers...), cfg.Ignore.Files...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s: ignore pattern %q: %v", file, pattern, err)
+		}
+	}
+	switch cfg.Generated {
+	case "", "skip", "rename":
+	default:
+		return nil, fmt.Errorf("%s: generated: want skip or rename, got %q", file, cfg.Generated)
+	}
+
+	s := &settings{
+		rules:         rules,
+		overrides:     cfg.Overrides,
+		ignoreIdents:  cfg.Ignore.Identifiers,
+		ignoreFiles:   cfg.Ignore.Files,
+		skipGenerated: cfg.Generated != "rename",
+	}
+	if file != "" {
+		s.dir = filepath.Dir(file)
+	}
+	p.settings[file] = s
+	return s, nil
+}
+
+// ignoresIdent reports whether name matches an ignore.identifiers pattern.
+func (s *settings) ignoresIdent(name string) bool {
+	for _, pattern := range s.ignoreIdents {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoresFile reports whether the declarations in file must be left alone,
+// because it matches an ignore.files pattern (by base name, or by path
+// relative to the config file) or because it is generated.
+func (s *settings) ignoresFile(filename string, file *ast.File) bool {
+	if s.skipGenerated && ast.IsGenerated(file) {
+		return true
+	}
+	rel := filepath.Base(filename)
+	if s.dir != "" {
+		if r, err := filepath.Rel(s.dir, filename); err == nil {
+			rel = filepath.ToSlash(r)
+		}
+	}
+	for _, pattern := range s.ignoreFiles {
+		if ok, _ := path.Match(pattern, filepath.Base(filename)); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
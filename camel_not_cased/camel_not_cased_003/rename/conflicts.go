@@ -0,0 +1,329 @@
+package rename
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// finalName returns the name obj will have once plan is applied.
+func (p *Plan) finalName(obj types.Object) string {
+	if name, ok := p.newName(obj); ok {
+		return name
+	}
+	return obj.Name()
+}
+
+// checkConflicts reports every place where applying plan would stop the code
+// from compiling or silently change what an identifier refers to.
+func checkConflicts(plan *Plan) []Diagnostic {
+	seen := map[string]bool{}
+	var conflicts []Diagnostic
+	report := func(obj types.Object, pos token.Pos, format string, args ...any) {
+		c := Diagnostic{Pos: plan.fset.Position(pos), Message: fmt.Sprintf(format, args...)}
+		if s := c.String(); !seen[s] {
+			seen[s] = true
+			conflicts = append(conflicts, c)
+			if key, ok := keyOf(plan.fset, obj); ok {
+				plan.conflictsOf[key] = append(plan.conflictsOf[key], c)
+			}
+		}
+	}
+
+	for _, pkg := range plan.pkgs {
+		checkScopes(pkg, plan, report)
+		checkMembers(pkg, plan, report)
+	}
+	for _, c := range exportChanges(plan) {
+		newName, _ := plan.newName(c.obj)
+		for _, u := range c.uses {
+			report(c.obj, u.pos, "renaming %s to %s would make it %s, but package %s refers to it here",
+				c.obj.Name(), newName, exportedness(newName), u.pkg)
+		}
+	}
+
+	sortDiagnostics(conflicts)
+	return conflicts
+}
+
+// use is an identifier that refers to a lexically scoped object.
+type use struct {
+	id  *ast.Ident
+	obj types.Object
+}
+
+// checkScopes looks for lexical conflicts of renamed objects declared in pkg:
+// another object with the new name in the same scope, references to the
+// renamed object that an inner declaration would capture, and references to
+// an outer object that the renamed object would shadow.
+func checkScopes(pkg *packages.Package, plan *Plan, report func(types.Object, token.Pos, string, ...any)) {
+	info := pkg.TypesInfo
+	pkgScope := pkg.Types.Scope()
+
+	// Qualified identifiers (pkg.Name, x.Field) are not resolved lexically.
+	qualified := map[*ast.Ident]bool{}
+	for _, file := range pkg.Syntax {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				qualified[sel.Sel] = true
+			}
+			return true
+		})
+	}
+
+	usesOf := map[types.Object][]use{}
+	usesByName := map[string][]use{}
+	for id, obj := range info.Uses {
+		if qualified[id] || obj.Parent() == nil {
+			continue
+		}
+		u := use{id: id, obj: obj}
+		usesOf[obj] = append(usesOf[obj], u)
+		name := plan.finalName(obj)
+		usesByName[name] = append(usesByName[name], u)
+	}
+
+	for id, obj := range info.Defs {
+		if obj == nil || id.Name == "_" || obj.Pkg() != pkg.Types || obj.Parent() == nil {
+			continue
+		}
+		newName, ok := plan.newName(obj)
+		if !ok || newName == obj.Name() {
+			continue
+		}
+		scope := obj.Parent()
+
+		// Redeclaration in the same scope.
+		for _, name := range scope.Names() {
+			other := scope.Lookup(name)
+			if other != obj && plan.finalName(other) == newName {
+				report(obj, obj.Pos(), "renaming %s to %s conflicts with %s declared at %s",
+					obj.Name(), newName, other.Name(), plan.fset.Position(other.Pos()))
+			}
+		}
+		// Package-level names also share a namespace with each file's imports.
+		if scope == pkgScope {
+			for _, file := range pkg.Syntax {
+				for _, imp := range file.Imports {
+					pkgName := info.PkgNameOf(imp)
+					if pkgName != nil && pkgName.Name() == newName {
+						report(obj, obj.Pos(), "renaming %s to %s conflicts with import %s at %s",
+							obj.Name(), newName, pkgName.Imported().Path(), plan.fset.Position(imp.Pos()))
+					}
+				}
+			}
+		}
+
+		// References to obj that an inner declaration of newName would capture.
+		for _, u := range usesOf[obj] {
+			for s := pkgScope.Innermost(u.id.Pos()); s != nil && s != scope; s = s.Parent() {
+				if inner := lookupFinal(s, plan, newName, u.id.Pos()); inner != nil {
+					report(obj, u.id.Pos(), "renaming %s to %s: this reference would refer to %s declared at %s",
+						obj.Name(), newName, inner.Name(), plan.fset.Position(inner.Pos()))
+					break
+				}
+			}
+		}
+
+		// References to an outer newName that obj would shadow.
+		for _, u := range usesByName[newName] {
+			if u.obj == obj || (scope != pkgScope && u.id.Pos() < obj.Pos()) {
+				continue
+			}
+			for s := pkgScope.Innermost(u.id.Pos()); s != nil && s != u.obj.Parent(); s = s.Parent() {
+				if s == scope {
+					report(obj, u.id.Pos(), "renaming %s to %s would shadow %s declared at %s",
+						obj.Name(), newName, u.obj.Name(), declPos(plan.fset, u.obj))
+					break
+				}
+			}
+		}
+	}
+}
+
+// lookupFinal returns the object in scope s (not its parents) whose final
+// name is name and which is visible at pos, if any.
+func lookupFinal(s *types.Scope, plan *Plan, name string, pos token.Pos) types.Object {
+	for _, n := range s.Names() {
+		obj := s.Lookup(n)
+		if plan.finalName(obj) != name {
+			continue
+		}
+		// Within a function, a declaration is only visible after it.
+		if obj.Pkg() != nil && s != obj.Pkg().Scope() && obj.Pos() > pos {
+			if _, isType := obj.(*types.TypeName); !isType {
+				continue
+			}
+		}
+		return obj
+	}
+	return nil
+}
+
+func declPos(fset *token.FileSet, obj types.Object) string {
+	if !obj.Pos().IsValid() {
+		return "universe scope"
+	}
+	return fset.Position(obj.Pos()).String()
+}
+
+// member is a field or method of a type, found at the given embedding depth.
+type member struct {
+	obj   types.Object
+	depth int
+}
+
+// checkMembers reports renamed fields and methods that would clash with
+// another field or method of a type declared in pkg, including members
+// promoted from embedded types.
+func checkMembers(pkg *packages.Package, plan *Plan, report func(types.Object, token.Pos, string, ...any)) {
+	for id, obj := range pkg.TypesInfo.Defs {
+		tn, ok := obj.(*types.TypeName)
+		if !ok || tn.IsAlias() || id.Name == "_" {
+			continue
+		}
+		members := collectMembers(tn.Type())
+		for _, m := range members {
+			newName, ok := plan.newName(m.obj)
+			if !ok || newName == m.obj.Name() {
+				continue
+			}
+			mKey, _ := keyOf(plan.fset, m.obj)
+			for _, o := range members {
+				if oKey, _ := keyOf(plan.fset, o.obj); oKey == mKey || plan.finalName(o.obj) != newName {
+					continue
+				}
+				var how string
+				switch {
+				case o.depth == m.depth:
+					how = "collides with"
+				case o.depth < m.depth:
+					how = "would be hidden by"
+				default:
+					how = "would hide promoted"
+				}
+				report(m.obj, m.obj.Pos(), "renaming %s.%s to %s %s %s declared at %s",
+					tn.Name(), m.obj.Name(), newName, how, o.obj.Name(), declPos(plan.fset, o.obj))
+			}
+		}
+	}
+}
+
+// collectMembers returns the fields and methods of t, including those
+// promoted through embedded fields.
+func collectMembers(t types.Type) []member {
+	var members []member
+	seen := map[*types.Named]bool{}
+	var walk func(t types.Type, depth int)
+	walk = func(t types.Type, depth int) {
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		if named, ok := types.Unalias(t).(*types.Named); ok {
+			if seen[named] {
+				return
+			}
+			seen[named] = true
+			for i := 0; i < named.NumMethods(); i++ {
+				members = append(members, member{named.Method(i), depth})
+			}
+		}
+		switch u := t.Underlying().(type) {
+		case *types.Struct:
+			for i := 0; i < u.NumFields(); i++ {
+				f := u.Field(i)
+				members = append(members, member{f, depth})
+				if f.Embedded() {
+					walk(f.Type(), depth+1)
+				}
+			}
+		case *types.Interface:
+			for i := 0; i < u.NumMethods(); i++ {
+				members = append(members, member{u.Method(i), depth})
+			}
+		}
+	}
+	walk(t, 0)
+	return members
+}
+
+// A crossUse is a reference to an object from a package other than its own.
+type crossUse struct {
+	pos token.Pos
+	pkg string // path of the referring package
+}
+
+// An exportChange is a renamed object whose new name changes whether it is
+// exported, with the references to it from other loaded packages.
+type exportChange struct {
+	obj  types.Object
+	uses []crossUse
+}
+
+// exportChanges returns the renames that change whether an object is
+// exported while another loaded package refers to it. That package is
+// rewritten to the new name too, but cannot use an unexported one.
+func exportChanges(plan *Plan) []*exportChange {
+	byKey := map[objectKey]*exportChange{}
+	var changes []*exportChange
+	for _, pkg := range plan.pkgs {
+		for id, obj := range pkg.TypesInfo.Uses {
+			// An embedded field is renamed along with its type.
+			if v, ok := obj.(*types.Var); ok && v.Embedded() {
+				if tn := embeddedTypeName(v.Type()); tn != nil {
+					obj = tn
+				}
+			}
+			if obj.Pkg() == nil || obj.Pkg().Path() == pkg.PkgPath {
+				continue
+			}
+			newName, ok := plan.newName(obj)
+			if !ok || token.IsExported(newName) == obj.Exported() {
+				continue
+			}
+			key, _ := keyOf(plan.fset, obj)
+			c := byKey[key]
+			if c == nil {
+				c = &exportChange{obj: obj}
+				byKey[key] = c
+				changes = append(changes, c)
+			}
+			c.uses = append(c.uses, crossUse{pos: id.Pos(), pkg: pkg.PkgPath})
+		}
+	}
+	return changes
+}
+
+// keepExports drops the renames chosen by the naming rules that would
+// change whether an object is exported while another loaded package
+// refers to it. A method is kept along with every method of the same name,
+// which interfaces may tie to it.
+func keepExports(plan *Plan) {
+	for _, c := range exportChanges(plan) {
+		newName, ok := plan.newName(c.obj)
+		if !ok {
+			continue // dropped along with a method of the same name
+		}
+		plan.unset(c.obj)
+		plan.warnf(c.obj.Pos(), "not renaming %s to %s: it would become %s, and package %s refers to it",
+			c.obj.Name(), newName, exportedness(newName), c.uses[0].pkg)
+		if objectKind(c.obj) != "method" {
+			continue
+		}
+		for key, r := range plan.names {
+			if r.Kind == "method" && r.Old == c.obj.Name() {
+				delete(plan.names, key)
+			}
+		}
+	}
+}
+
+func exportedness(name string) string {
+	if token.IsExported(name) {
+		return "exported"
+	}
+	return "unexported"
+}
@@ -0,0 +1,180 @@
+package rename
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each hunk.
+const diffContext = 3
+
+// diffOp is one line of an edit script: ' ' keeps, '-' deletes, '+' inserts.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns a unified diff turning a into b, in the same layout
+// as gofmt -d, or "" if they are equal.
+func unifiedDiff(filename string, a, b []byte) string {
+	if bytes.Equal(a, b) {
+		return ""
+	}
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "diff %s.orig %s\n", filename, filename)
+	fmt.Fprintf(&out, "--- %s.orig\n", filename)
+	fmt.Fprintf(&out, "+++ %s\n", filename)
+
+	for start := 0; start < len(ops); {
+		// Find the next change and extend the hunk while changes are close.
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		end := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				end = i + 1
+			} else if i-end >= 2*diffContext {
+				break
+			}
+		}
+		lo := max(start-diffContext, 0)
+		hi := min(end+diffContext, len(ops))
+		writeHunk(&out, ops, lo, hi)
+		start = hi
+	}
+	return out.String()
+}
+
+// writeHunk writes ops[lo:hi] as a single hunk.
+func writeHunk(out *strings.Builder, ops []diffOp, lo, hi int) {
+	// Line numbers of ops[lo] in a and b.
+	aLine, bLine := 1, 1
+	for _, op := range ops[:lo] {
+		if op.kind != '+' {
+			aLine++
+		}
+		if op.kind != '-' {
+			bLine++
+		}
+	}
+	aCount, bCount := 0, 0
+	for _, op := range ops[lo:hi] {
+		if op.kind != '+' {
+			aCount++
+		}
+		if op.kind != '-' {
+			bCount++
+		}
+	}
+	// An empty range is numbered after the line before it.
+	if aCount == 0 {
+		aLine--
+	}
+	if bCount == 0 {
+		bLine--
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aLine, aCount), hunkRange(bLine, bCount))
+	for _, op := range ops[lo:hi] {
+		out.WriteByte(op.kind)
+		out.WriteString(op.line)
+		if !strings.HasSuffix(op.line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(line, count int) string {
+	if count == 1 {
+		return fmt.Sprint(line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+// splitLines splits b after each newline, keeping the newlines.
+func splitLines(b []byte) []string {
+	var lines []string
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n') + 1
+		if i == 0 {
+			i = len(b)
+		}
+		lines = append(lines, string(b[:i]))
+		b = b[i:]
+	}
+	return lines
+}
+
+// diffLines computes a shortest edit script from a to b using Myers'
+// O(ND) algorithm.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace backwards to recover the edits.
+	var ops []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			ops = append(ops, diffOp{'+', b[y-1]})
+		} else {
+			ops = append(ops, diffOp{'-', a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, diffOp{' ', a[x-1]})
+		x--
+		y--
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
@@ -0,0 +1,98 @@
+package rename
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"os"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// textEdit replaces the bytes [start, end) of a file with text.
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+// applyEdits splices edits into src. Identical edits are applied once;
+// overlapping edits are an error.
+func applyEdits(src []byte, edits []textEdit) ([]byte, error) {
+	sorted := append([]textEdit(nil), edits...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].start != sorted[j].start {
+			return sorted[i].start < sorted[j].start
+		}
+		return sorted[i].end < sorted[j].end
+	})
+
+	var out bytes.Buffer
+	last := 0
+	for i, e := range sorted {
+		if i > 0 && e == sorted[i-1] {
+			continue
+		}
+		if e.start < last || e.end < e.start || e.end > len(src) {
+			return nil, fmt.Errorf("edit %d-%d overlaps another edit or is out of range", e.start, e.end)
+		}
+		out.Write(src[last:e.start])
+		out.WriteString(e.text)
+		last = e.end
+	}
+	out.Write(src[last:])
+	return out.Bytes(), nil
+}
+
+// sourceFile is the content of a source file, with the offsets at which
+// its lines start.
+type sourceFile struct {
+	src   []byte
+	lines []int
+}
+
+// source returns the content of filename, reading it once.
+func (p *Plan) source(filename string) (*sourceFile, error) {
+	if sf, ok := p.sources[filename]; ok {
+		return sf, nil
+	}
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	sf := &sourceFile{src: src, lines: []int{0}}
+	for i, b := range src {
+		if b == '\n' {
+			sf.lines = append(sf.lines, i+1)
+		}
+	}
+	p.sources[filename] = sf
+	return sf, nil
+}
+
+// isSourceFile reports whether filename is one of the Go source files of
+// pkg, rather than a file that cgo generated from one.
+func isSourceFile(pkg *packages.Package, filename string) bool {
+	for _, f := range pkg.GoFiles {
+		if f == filename {
+			return true
+		}
+	}
+	return false
+}
+
+// cgoOffset returns the source file and byte offset of pos, a position in
+// a file generated by cgo. cgo marks the Go code it copies with //line
+// directives that give the line and column it came from.
+func (p *Plan) cgoOffset(pos token.Pos) (string, int, bool) {
+	adjusted := p.fset.Position(pos)
+	sf, err := p.source(adjusted.Filename)
+	if err != nil || adjusted.Line < 1 || adjusted.Line > len(sf.lines) {
+		return "", 0, false
+	}
+	offset := sf.lines[adjusted.Line-1] + adjusted.Column - 1
+	if offset > len(sf.src) {
+		return "", 0, false
+	}
+	return adjusted.Filename, offset, true
+}
@@ -0,0 +1,268 @@
+package rename
+
+import (
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// methodClasses groups methods whose names must stay equal: an interface
+// method and every method that implements it (directly, or through another
+// interface). It is a union-find over object keys.
+type methodClasses struct {
+	parent  map[objectKey]objectKey
+	objects map[objectKey]types.Object
+}
+
+func (c *methodClasses) add(key objectKey, obj types.Object) {
+	if _, ok := c.parent[key]; !ok {
+		c.parent[key] = key
+		c.objects[key] = obj
+	}
+}
+
+func (c *methodClasses) find(key objectKey) objectKey {
+	for c.parent[key] != key {
+		c.parent[key] = c.parent[c.parent[key]]
+		key = c.parent[key]
+	}
+	return key
+}
+
+func (c *methodClasses) union(a, b objectKey) {
+	if ra, rb := c.find(a), c.find(b); ra != rb {
+		c.parent[ra] = rb
+	}
+}
+
+// unifyMethods extends plan so that methods related by interface
+// satisfaction are renamed together, and drops the rename of any method
+// that an interface outside the loaded packages (io.Writer, fmt.Stringer,
+// ...) requires, since that interface cannot be changed.
+func unifyMethods(plan *Plan) {
+	pkgs := plan.pkgs
+	// The old names of renamed methods; only these need linking.
+	names := map[string]bool{}
+	local := map[string]bool{} // paths of packages whose files we rewrite
+	for _, pkg := range pkgs {
+		local[pkg.PkgPath] = true
+		for _, obj := range pkg.TypesInfo.Defs {
+			if _, ok := obj.(*types.Func); ok && objectKind(obj) == "method" {
+				if newName, ok := plan.newName(obj); ok && newName != obj.Name() {
+					names[obj.Name()] = true
+				}
+			}
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+
+	ifaces := collectInterfaces(pkgs, names)
+	concrete := collectConcreteTypes(pkgs, names)
+
+	classes := &methodClasses{parent: map[objectKey]objectKey{}, objects: map[objectKey]types.Object{}}
+	link := func(t types.Type, iface *types.Interface) {
+		for i := 0; i < iface.NumMethods(); i++ {
+			m := iface.Method(i)
+			if !names[m.Name()] {
+				continue
+			}
+			impl, _, _ := types.LookupFieldOrMethod(t, true, m.Pkg(), m.Name())
+			iKey, ok := keyOf(plan.fset, impl)
+			if impl == nil || !ok {
+				continue
+			}
+			mKey, ok := keyOf(plan.fset, m)
+			if !ok {
+				// A predeclared method, such as error's Error.
+				mKey = objectKey{file: "builtin", line: i}
+			}
+			classes.add(mKey, m)
+			classes.add(iKey, impl)
+			classes.union(mKey, iKey)
+		}
+	}
+	for _, iface := range ifaces {
+		for _, t := range concrete {
+			if types.Implements(t, iface) || types.Implements(types.NewPointer(t), iface) {
+				link(t, iface)
+			}
+		}
+		// An interface that embeds or otherwise satisfies another must keep
+		// its methods in step with it.
+		for _, other := range ifaces {
+			if other != iface && types.Implements(other, iface) {
+				link(other, iface)
+			}
+		}
+	}
+
+	// Resolve each class to a single outcome.
+	members := map[objectKey][]objectKey{}
+	for key := range classes.parent {
+		root := classes.find(key)
+		members[root] = append(members[root], key)
+	}
+	classList := make([][]objectKey, 0, len(members))
+	for _, keys := range members {
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		classList = append(classList, keys)
+	}
+	sort.Slice(classList, func(i, j int) bool { return classList[i][0].String() < classList[j][0].String() })
+	for _, keys := range classList {
+		resolveClass(keys, classes.objects, local, plan)
+	}
+}
+
+// resolveClass renames every method in a class to the same name, or none
+// of them if one is declared outside the loaded packages.
+func resolveClass(keys []objectKey, objects map[objectKey]types.Object, local map[string]bool, plan *Plan) {
+	var newName string
+	var renamed bool
+	for _, key := range keys {
+		obj := objects[key]
+		if name, ok := plan.newName(obj); ok && name != obj.Name() {
+			renamed = true
+			// Prefer the name chosen for an interface method.
+			if newName == "" || isInterfaceMethod(obj) {
+				newName = name
+			}
+		}
+	}
+	if !renamed {
+		return
+	}
+
+	for _, key := range keys {
+		obj := objects[key]
+		path := "builtin"
+		if obj.Pkg() != nil {
+			path = obj.Pkg().Path()
+		}
+		if local[path] {
+			continue
+		}
+		// Required by an interface we cannot change.
+		for _, k := range keys {
+			o := objects[k]
+			if _, ok := plan.newName(o); ok {
+				plan.unset(o)
+				plan.warnf(o.Pos(), "not renaming method %s: it is required by %s in package %s",
+					o.Name(), describeMethod(obj), path)
+			}
+		}
+		return
+	}
+
+	for _, key := range keys {
+		obj := objects[key]
+		if name, ok := plan.newName(obj); !ok || name != newName {
+			plan.set(obj, newName)
+		}
+	}
+}
+
+// isInterfaceMethod reports whether obj is a method declared in an interface.
+func isInterfaceMethod(obj types.Object) bool {
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+	sig, ok := fn.Type().(*types.Signature)
+	return ok && sig.Recv() != nil && types.IsInterface(sig.Recv().Type())
+}
+
+// describeMethod names a method as Recv.Method.
+func describeMethod(obj types.Object) string {
+	if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+		t := sig.Recv().Type()
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		if named, ok := t.(*types.Named); ok {
+			return named.Obj().Name() + "." + obj.Name()
+		}
+		return "interface method " + obj.Name()
+	}
+	return obj.Name()
+}
+
+// collectInterfaces returns every interface, named or not, visible to the
+// loaded packages (including their dependencies) that declares a method
+// with one of the given names.
+func collectInterfaces(pkgs []*packages.Package, names map[string]bool) []*types.Interface {
+	seen := map[*types.Interface]bool{}
+	var ifaces []*types.Interface
+	add := func(t types.Type) {
+		iface, ok := t.Underlying().(*types.Interface)
+		if !ok || seen[iface] {
+			return
+		}
+		seen[iface] = true
+		for i := 0; i < iface.NumMethods(); i++ {
+			if names[iface.Method(i).Name()] {
+				ifaces = append(ifaces, iface)
+				return
+			}
+		}
+	}
+
+	add(types.Universe.Lookup("error").Type())
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if pkg.Types == nil {
+			return
+		}
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			if tn, ok := scope.Lookup(name).(*types.TypeName); ok && !isGeneric(tn.Type()) {
+				add(tn.Type())
+			}
+		}
+	})
+	for _, pkg := range pkgs {
+		for _, obj := range pkg.TypesInfo.Defs {
+			if tn, ok := obj.(*types.TypeName); ok && !isGeneric(tn.Type()) {
+				add(tn.Type())
+			}
+		}
+		for _, tv := range pkg.TypesInfo.Types {
+			if tv.IsType() && !isGeneric(tv.Type) {
+				add(tv.Type)
+			}
+		}
+	}
+	return ifaces
+}
+
+// collectConcreteTypes returns the non-interface named types declared in
+// the loaded packages that have a method with one of the given names.
+func collectConcreteTypes(pkgs []*packages.Package, names map[string]bool) []types.Type {
+	seen := map[types.Type]bool{}
+	var concrete []types.Type
+	for _, pkg := range pkgs {
+		for _, obj := range pkg.TypesInfo.Defs {
+			tn, ok := obj.(*types.TypeName)
+			if !ok || tn.IsAlias() || types.IsInterface(tn.Type()) || isGeneric(tn.Type()) || seen[tn.Type()] {
+				continue
+			}
+			seen[tn.Type()] = true
+			mset := types.NewMethodSet(types.NewPointer(tn.Type()))
+			for i := 0; i < mset.Len(); i++ {
+				if names[mset.At(i).Obj().Name()] {
+					concrete = append(concrete, tn.Type())
+					break
+				}
+			}
+		}
+	}
+	return concrete
+}
+
+// isGeneric reports whether t is a generic type that has not been
+// instantiated; interface satisfaction is not defined for it.
+func isGeneric(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.TypeParams().Len() > 0 && named.TypeArgs().Len() == 0
+}
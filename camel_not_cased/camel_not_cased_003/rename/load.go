@@ -0,0 +1,321 @@
+package rename
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const loadMode = packages.NeedName |
+	packages.NeedFiles |
+	packages.NeedCompiledGoFiles |
+	packages.NeedImports |
+	packages.NeedDeps |
+	packages.NeedSyntax |
+	packages.NeedTypes |
+	packages.NeedTypesInfo |
+	packages.NeedTypesSizes |
+	packages.NeedModule
+
+// Load loads the packages named by args, with the syntax and type
+// information that renaming needs. With Options.Module, every argument is a
+// directory that is searched for go.mod files, and each module found is
+// loaded in full (including tests), so that renaming an exported identifier
+// also updates all of its importers.
+//
+// With Options.Variants, the packages are also loaded under every other
+// GOOS, GOARCH and build tag combination that their files need, so that
+// platform-specific files are renamed along with the rest. The result then
+// holds one package per variant; PlanRenames merges them.
+func Load(fset *token.FileSet, args []string, opts *Options) ([]*packages.Package, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	loaded, err := load(fset, args, opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []*packages.Package
+	var errs []error
+	for _, l := range loaded {
+		if len(l.errs) == 0 {
+			pkgs = append(pkgs, l.pkg)
+			continue
+		}
+		// The packages named on the command line must type-check, at least
+		// for the host.
+		if !opts.Module && l.host {
+			errs = append(errs, l.errs...)
+			continue
+		}
+		// A package that does not type-check cannot be renamed safely;
+		// report it and leave its files alone.
+		for _, err := range l.errs {
+			opts.logf("%v", err)
+		}
+		if opts.Variants {
+			opts.logf("skipping package %s for %s: it has errors", l.pkg.ID, l.variant)
+		} else {
+			opts.logf("skipping package %s: it has errors", l.pkg.ID)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return pkgs, nil
+}
+
+// Verify type-checks the packages of plan again, in memory, with the files
+// of report in place of the originals, and returns the errors found in
+// packages that had none before. Nothing is written. args and the options
+// of plan must be those the packages were loaded with.
+//
+// Without Options.Module, the rest of each module the packages belong to
+// is checked too, tests included: the importers of a renamed exported
+// identifier were not loaded, so they were not updated and no longer
+// build. Their errors say so.
+func Verify(args []string, plan *Plan, report *Report) error {
+	overlay := map[string][]byte{}
+	for _, c := range report.Changes {
+		if c.Changed() {
+			overlay[c.Filename] = c.Out
+		}
+	}
+	if len(overlay) == 0 {
+		return nil
+	}
+	before := map[string]bool{}
+	for _, pkg := range plan.pkgs {
+		before[packageKey(pkg)] = true
+	}
+
+	loaded, err := load(token.NewFileSet(), args, plan.opts, overlay)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	seen := map[string]bool{} // packages share the errors of dependencies
+	add := func(err error) {
+		if !seen[err.Error()] {
+			seen[err.Error()] = true
+			errs = append(errs, err)
+		}
+	}
+	for _, l := range loaded {
+		if !before[packageKey(l.pkg)] {
+			continue
+		}
+		for _, err := range l.errs {
+			add(err)
+		}
+	}
+	if plan.opts.Module {
+		return errors.Join(errs...)
+	}
+
+	for _, dir := range moduleDirs(plan.pkgs) {
+		broken, err := verifyModule(dir, plan.opts, overlay, before)
+		if err != nil {
+			plan.opts.logf("verify %s: %v (skipping the rest of the module)", dir, err)
+			continue
+		}
+		for _, err := range broken {
+			add(err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// moduleDirs returns the directories of the modules pkgs belong to.
+func moduleDirs(pkgs []*packages.Package) []string {
+	var dirs []string
+	seen := map[string]bool{}
+	for _, pkg := range pkgs {
+		if pkg.Module != nil && pkg.Module.Dir != "" && !seen[pkg.Module.Dir] {
+			seen[pkg.Module.Dir] = true
+			dirs = append(dirs, pkg.Module.Dir)
+		}
+	}
+	return dirs
+}
+
+// verifyModule loads the module in dir with and without overlay, and
+// returns the errors of the packages other than those in renamed that
+// type-checked before and do not after.
+func verifyModule(dir string, opts *Options, overlay map[string][]byte, renamed map[string]bool) ([]error, error) {
+	clean := map[string]bool{}
+	original, err := loadVariants(token.NewFileSet(), &packages.Config{Dir: dir, Tests: true}, []string{"./..."}, opts)
+	if err != nil {
+		return nil, err
+	}
+	// Only the errors of each package itself count: those of its
+	// dependencies are the business of the packages they are found in.
+	for _, l := range original {
+		if len(l.pkg.Errors) == 0 {
+			clean[packageKey(l.pkg)] = true
+		}
+	}
+
+	after, err := loadVariants(token.NewFileSet(), &packages.Config{Dir: dir, Tests: true, Overlay: overlay}, []string{"./..."}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var broken []error
+	for _, l := range after {
+		key := packageKey(l.pkg)
+		if renamed[key] || !clean[key] || len(l.pkg.Errors) == 0 {
+			continue
+		}
+		var errs []error
+		for _, err := range l.pkg.Errors {
+			errs = append(errs, err)
+		}
+		broken = append(broken, fmt.Errorf("%s was not loaded, so its uses of renamed identifiers were not updated (rename with -module to update it):\n%w",
+			l.pkg.ID, errors.Join(errs...)))
+	}
+	return broken, nil
+}
+
+// packageKey identifies a package in one build variant: variants of a
+// package share its ID but not its files.
+func packageKey(pkg *packages.Package) string {
+	return pkg.ID + "\x00" + strings.Join(pkg.GoFiles, "\x00")
+}
+
+// loadedPackage is a package loaded in one build variant, with the errors
+// found in it and its dependencies.
+type loadedPackage struct {
+	pkg     *packages.Package
+	variant buildVariant
+	host    bool // loaded in the host configuration
+	errs    []error
+}
+
+// load loads the packages named by args, as described for Load, with the
+// contents of files replaced by those in overlay.
+func load(fset *token.FileSet, args []string, opts *Options, overlay map[string][]byte) ([]loadedPackage, error) {
+	if !opts.Module {
+		return loadVariants(fset, &packages.Config{Overlay: overlay}, args, opts)
+	}
+
+	var modules []string
+	for _, root := range args {
+		found, err := findModules(root)
+		if err != nil {
+			return nil, err
+		}
+		modules = append(modules, found...)
+	}
+	if len(modules) == 0 {
+		return nil, fmt.Errorf("no go.mod found under %s", strings.Join(args, ", "))
+	}
+
+	var all []loadedPackage
+	for _, dir := range modules {
+		loaded, err := loadVariants(fset, &packages.Config{Dir: dir, Tests: true, Overlay: overlay}, []string{"./..."}, opts)
+		if err != nil {
+			opts.logf("load %s: %v (skipping module)", dir, err)
+			continue
+		}
+		all = append(all, loaded...)
+	}
+	return all, nil
+}
+
+// loadVariants loads patterns with the settings of base, under the host
+// build configuration and, with Options.Variants, under the others that
+// their files need. It fails only if the host configuration cannot be
+// loaded at all; other configurations whose dependencies are not
+// available are reported and skipped.
+func loadVariants(fset *token.FileSet, base *packages.Config, patterns []string, opts *Options) ([]loadedPackage, error) {
+	variants := []buildVariant{hostVariant()}
+	if opts.Variants {
+		cfg := *base
+		cfg.Mode = packages.NeedName | packages.NeedFiles
+		pkgs, err := packages.Load(&cfg, patterns...)
+		if err != nil {
+			return nil, err
+		}
+		var files []string
+		seen := map[string]bool{}
+		for _, pkg := range pkgs {
+			for _, f := range append(append([]string{}, pkg.GoFiles...), pkg.IgnoredFiles...) {
+				if strings.HasSuffix(f, ".go") && !seen[f] {
+					seen[f] = true
+					files = append(files, f)
+				}
+			}
+		}
+		var uncovered []string
+		variants, uncovered, err = buildVariants(files)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range uncovered {
+			opts.logf("%s: no supported build configuration includes this file", f)
+		}
+	}
+
+	var all []loadedPackage
+	for i, v := range variants {
+		host := i == 0
+		if !host {
+			opts.logf("loading %s for %s", strings.Join(patterns, " "), v)
+		}
+		cfg := *base
+		cfg.Mode = loadMode
+		cfg.Fset = fset
+		cfg.Env = v.env()
+		cfg.BuildFlags = v.buildFlags()
+		pkgs, err := packages.Load(&cfg, patterns...)
+		if err != nil {
+			if host {
+				return nil, err
+			}
+			opts.logf("load %s: %v (skipping)", v, err)
+			continue
+		}
+		for _, pkg := range pkgs {
+			l := loadedPackage{pkg: pkg, variant: v, host: host}
+			packages.Visit([]*packages.Package{pkg}, nil, func(p *packages.Package) {
+				for _, err := range p.Errors {
+					l.errs = append(l.errs, err)
+				}
+			})
+			all = append(all, l)
+		}
+	}
+	return all, nil
+}
+
+// findModules returns the directory of every go.mod file under root,
+// skipping vendor and testdata directories and hidden directories.
+func findModules(root string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == "go.mod" {
+			dir, err := filepath.Abs(filepath.Dir(path))
+			if err != nil {
+				return err
+			}
+			dirs = append(dirs, dir)
+		}
+		return nil
+	})
+	return dirs, err
+}
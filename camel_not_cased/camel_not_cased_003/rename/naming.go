@@ -0,0 +1,340 @@
+package rename
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// namingStyle is a target naming convention for identifiers.
+type namingStyle string
+
+const (
+	styleKeep        namingStyle = "keep"              // leave the identifier alone
+	styleSnake       namingStyle = "snake_case"        // foo_bar
+	styleCamel       namingStyle = "camelCase"         // fooBar
+	stylePascal      namingStyle = "PascalCase"        // FooBar
+	styleScreaming   namingStyle = "SCREAMING_SNAKE"   // FOO_BAR
+	styleCapitalized namingStyle = "Capitalized_snake" // Foo_bar, as in Add_to_path
+	styleMixed       namingStyle = "mixedcaps"         // fooBar or FooBar, as Go does it
+)
+
+// styleAliases maps every accepted spelling of a style to the style.
+var styleAliases = map[string]namingStyle{
+	"keep":              styleKeep,
+	"none":              styleKeep,
+	"snake":             styleSnake,
+	"snake_case":        styleSnake,
+	"camel":             styleCamel,
+	"camelcase":         styleCamel,
+	"pascal":            stylePascal,
+	"pascalcase":        stylePascal,
+	"screaming":         styleScreaming,
+	"screaming_snake":   styleScreaming,
+	"capitalized":       styleCapitalized,
+	"capitalized_snake": styleCapitalized,
+	"mixedcaps":         styleMixed,
+	"mixed_caps":        styleMixed,
+	"go":                styleMixed,
+}
+
+// commonInitialisms are the words that Go names spell in a single case,
+// as in ServeHTTP and userID, in the mixedcaps style.
+var commonInitialisms = []string{
+	"ACL", "API", "ASCII", "CPU", "CSS", "DNS", "EOF", "GUID", "HTML", "HTTP", "HTTPS", "ID", "IP",
+	"JSON", "LHS", "QPS", "RAM", "RHS", "RPC", "SLA", "SMTP", "SQL", "SSH", "TCP", "TLS", "TTL",
+	"UDP", "UI", "UID", "UUID", "URI", "URL", "UTF8", "VM", "XML", "XMPP", "XSRF", "XSS",
+}
+
+// objectKinds lists the kinds that may appear on the left of a -rules entry.
+// Each may also be prefixed with "exported-" or "unexported-".
+var objectKinds = []string{"const", "var", "local", "func", "method", "type", "field", "label"}
+
+func parseStyle(s string) (namingStyle, error) {
+	if style, ok := styleAliases[strings.ToLower(strings.TrimSpace(s))]; ok {
+		return style, nil
+	}
+	names := make([]string, 0, len(styleAliases))
+	for name := range styleAliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("unknown style %q (want one of %s)", s, strings.Join(names, ", "))
+}
+
+// namingRules picks a style for each object based on its kind.
+type namingRules struct {
+	def      namingStyle
+	byKind   map[string]namingStyle
+	acronyms []string // words kept whole when splitting, e.g. IPv6, OAuth2
+}
+
+// parseRules parses a comma-separated list of kind=style pairs, e.g.
+// "const=SCREAMING_SNAKE,exported-func=Capitalized_snake,local=snake".
+func parseRules(def namingStyle, spec string) (*namingRules, error) {
+	rules := &namingRules{def: def, byKind: map[string]namingStyle{}}
+	if strings.TrimSpace(spec) == "" {
+		return rules, nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		kind, styleName, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("rule %q: want kind=style", entry)
+		}
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		base := strings.TrimPrefix(strings.TrimPrefix(kind, "exported-"), "unexported-")
+		if !validKind(base) {
+			return nil, fmt.Errorf("rule %q: unknown kind %q (want one of %s)", entry, base, strings.Join(objectKinds, ", "))
+		}
+		style, err := parseStyle(styleName)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %v", entry, err)
+		}
+		rules.byKind[kind] = style
+	}
+	return rules, nil
+}
+
+func validKind(kind string) bool {
+	for _, k := range objectKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// styleFor returns the style that applies to obj. The most specific rule
+// wins: "exported-func" beats "func", which beats the default style.
+func (r *namingRules) styleFor(obj types.Object) namingStyle {
+	kind := objectKind(obj)
+	prefix := "unexported-"
+	if obj.Exported() {
+		prefix = "exported-"
+	}
+	if style, ok := r.byKind[prefix+kind]; ok {
+		return style
+	}
+	if style, ok := r.byKind[kind]; ok {
+		return style
+	}
+	return r.def
+}
+
+// convert returns the name obj should have under r.
+func (r *namingRules) convert(obj types.Object) string {
+	return convertName(obj.Name(), r.styleFor(obj), r.acronyms)
+}
+
+// objectKind classifies obj into one of objectKinds.
+func objectKind(obj types.Object) string {
+	switch obj := obj.(type) {
+	case *types.Const:
+		return "const"
+	case *types.TypeName:
+		return "type"
+	case *types.Func:
+		if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+			return "method"
+		}
+		return "func"
+	case *types.Var:
+		if obj.IsField() {
+			return "field"
+		}
+		if obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope() {
+			return "var"
+		}
+		return "local"
+	case *types.Label:
+		return "label"
+	}
+	return "local"
+}
+
+// isSpecialFunc reports whether obj is a function whose name is fixed by the
+// language or the go tool (main, init, TestXxx, ...), so it must not be renamed.
+func isSpecialFunc(obj types.Object) bool {
+	fn, ok := obj.(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Parent() != fn.Pkg().Scope() {
+		return false
+	}
+	name := fn.Name()
+	if name == "init" || (name == "main" && fn.Pkg().Name() == "main") {
+		return true
+	}
+	for _, prefix := range []string{"Test", "Benchmark", "Example", "Fuzz"} {
+		if name == prefix {
+			return true
+		}
+		if strings.HasPrefix(name, prefix) {
+			r, _ := utf8.DecodeRuneInString(name[len(prefix):])
+			if !unicode.IsLower(r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitWords breaks an identifier into its words, splitting on underscores
+// and on case changes: "HTTPServer" -> [HTTP Server], "fooBar2Baz" -> [foo Bar2 Baz].
+// A word that starts with one of the acronyms (matched case-sensitively,
+// longest first) is split after it instead: with IPv6 in the list,
+// "IPv6Addr" -> [IPv6 Addr] rather than [I Pv6 Addr].
+func splitWords(s string, acronyms []string) []string {
+	var words []string
+	for _, part := range strings.Split(s, "_") {
+		if part == "" {
+			continue
+		}
+		runes := []rune(part)
+		start := 0
+		for i := 1; i <= len(runes); i++ {
+			if i-1 == start {
+				if n := matchAcronym(runes[start:], acronyms); n > 0 {
+					words = append(words, string(runes[start:start+n]))
+					start += n
+					i = start
+					continue
+				}
+			}
+			if i == len(runes) {
+				break
+			}
+			prev, cur := runes[i-1], runes[i]
+			var next rune
+			if i+1 < len(runes) {
+				next = runes[i+1]
+			}
+			lowerToUpper := (unicode.IsLower(prev) || unicode.IsDigit(prev)) && unicode.IsUpper(cur)
+			acronymEnd := unicode.IsUpper(prev) && unicode.IsUpper(cur) && unicode.IsLower(next)
+			if lowerToUpper || acronymEnd {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			words = append(words, string(runes[start:]))
+		}
+	}
+	return words
+}
+
+// matchAcronym returns the length in runes of the longest acronym that
+// runes starts with and that is not followed by a lower-case letter, or 0.
+func matchAcronym(runes []rune, acronyms []string) int {
+	best := 0
+	for _, a := range acronyms {
+		ar := []rune(a)
+		if len(ar) <= best || len(ar) > len(runes) || string(runes[:len(ar)]) != a {
+			continue
+		}
+		if len(ar) < len(runes) && unicode.IsLower(runes[len(ar)]) {
+			continue
+		}
+		best = len(ar)
+	}
+	return best
+}
+
+// canonicalAcronym returns the dictionary spelling of w if it is an acronym
+// in any case (ipv6 -> IPv6).
+func canonicalAcronym(w string, acronyms []string) (string, bool) {
+	for _, a := range acronyms {
+		if strings.EqualFold(a, w) {
+			return a, true
+		}
+	}
+	return "", false
+}
+
+// convertName renders s in the given style. Leading and trailing underscores
+// are preserved, and single-character names are left alone. In camelCase and
+// PascalCase, acronyms keep their dictionary spelling. mixedcaps is camelCase
+// or PascalCase depending on whether s is exported, so it never changes
+// exportedness, and it also spells the common initialisms in upper case.
+func convertName(s string, style namingStyle, acronyms []string) string {
+	if style == styleKeep || s == "" || s == "_" || utf8.RuneCountInString(s) == 1 {
+		return s
+	}
+	core := strings.Trim(s, "_")
+	if core == "" {
+		return s
+	}
+	lead := s[:strings.Index(s, core)]
+	trail := s[len(lead)+len(core):]
+
+	words := splitWords(core, acronyms)
+	if style == styleMixed {
+		return lead + mixedCaps(words, token.IsExported(core), acronyms) + trail
+	}
+	out := make([]string, len(words))
+	for i, w := range words {
+		if a, ok := canonicalAcronym(w, acronyms); ok && (style == stylePascal || style == styleCamel && i > 0) {
+			out[i] = a
+			continue
+		}
+		switch style {
+		case styleSnake:
+			out[i] = strings.ToLower(w)
+		case styleScreaming:
+			out[i] = strings.ToUpper(w)
+		case stylePascal:
+			out[i] = title(w)
+		case styleCamel:
+			if i == 0 {
+				out[i] = strings.ToLower(w)
+			} else {
+				out[i] = title(w)
+			}
+		case styleCapitalized:
+			if i == 0 {
+				out[i] = title(w)
+			} else {
+				out[i] = strings.ToLower(w)
+			}
+		}
+	}
+
+	sep := "_"
+	if style == styleCamel || style == stylePascal {
+		sep = ""
+	}
+	return lead + strings.Join(out, sep) + trail
+}
+
+// mixedCaps joins words in MixedCaps, or mixedCaps if not exported.
+func mixedCaps(words []string, exported bool, acronyms []string) string {
+	var b strings.Builder
+	for i, w := range words {
+		switch {
+		case i == 0 && !exported:
+			b.WriteString(strings.ToLower(w))
+		default:
+			if a, ok := canonicalAcronym(w, acronyms); ok {
+				b.WriteString(a)
+			} else if _, ok := canonicalAcronym(w, commonInitialisms); ok {
+				b.WriteString(strings.ToUpper(w))
+			} else {
+				b.WriteString(title(w))
+			}
+		}
+	}
+	return b.String()
+}
+
+// title upper-cases the first letter of w and lower-cases the rest.
+func title(w string) string {
+	r, size := utf8.DecodeRuneInString(w)
+	return string(unicode.ToUpper(r)) + strings.ToLower(w[size:])
+}
+
+// isValidIdent reports whether s can be used as a Go identifier.
+func isValidIdent(s string) bool {
+	return token.IsIdentifier(s)
+}
@@ -0,0 +1,59 @@
+package rename
+
+import "testing"
+
+func TestConvertName(t *testing.T) {
+	tests := []struct {
+		in    string
+		style namingStyle
+		want  string
+	}{
+		{"getFileSize", styleSnake, "get_file_size"},
+		{"HTTPServer", styleSnake, "http_server"},
+		{"parseURLQuery", styleSnake, "parse_url_query"},
+		{"_privateThing", styleSnake, "_private_thing"},
+		{"x", styleSnake, "x"},
+		{"add_to_path", styleCamel, "addToPath"},
+		{"add_to_path", stylePascal, "AddToPath"},
+		{"maxRetryCount", styleScreaming, "MAX_RETRY_COUNT"},
+		{"GetFileSize", styleCapitalized, "Get_file_size"},
+		{"GetFileSize", styleKeep, "GetFileSize"},
+		{"Get_file_size", styleMixed, "GetFileSize"},
+		{"get_user_id", styleMixed, "getUserID"},
+		{"Http_server", styleMixed, "HTTPServer"},
+		{"url_path", styleMixed, "urlPath"},
+		{"serveHttp", styleMixed, "serveHTTP"},
+		{"_local_name", styleMixed, "_localName"},
+	}
+	for _, tt := range tests {
+		if got := convertName(tt.in, tt.style, nil); got != tt.want {
+			t.Errorf("convertName(%q, %s) = %q, want %q", tt.in, tt.style, got, tt.want)
+		}
+	}
+}
+
+func TestConvertNameAcronyms(t *testing.T) {
+	acronyms := []string{"IPv6", "OAuth2"}
+	tests := []struct {
+		in, want string
+	}{
+		{"IPv6Addr", "ipv6_addr"},
+		{"newOAuth2Token", "new_oauth2_token"},
+	}
+	for _, tt := range tests {
+		if got := convertName(tt.in, styleSnake, acronyms); got != tt.want {
+			t.Errorf("convertName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	if _, err := parseRules(styleSnake, "const=SCREAMING_SNAKE,exported-func=Capitalized_snake"); err != nil {
+		t.Errorf("valid rules: %v", err)
+	}
+	for _, bad := range []string{"const", "widget=snake_case", "const=shouting"} {
+		if _, err := parseRules(styleSnake, bad); err == nil {
+			t.Errorf("parseRules(%q): want error", bad)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package rename
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// objectKey identifies an object by the position of its declaration. Unlike
+// a types.Object it is stable across separate packages.Load calls, so a
+// rename decided while processing one package can be recognised at the use
+// sites in every package that imports it.
+type objectKey struct {
+	file      string
+	line, col int
+}
+
+func (k objectKey) String() string {
+	return fmt.Sprintf("%s:%d:%d", k.file, k.line, k.col)
+}
+
+// keyOf returns the key of obj. Objects without a source position
+// (predeclared identifiers, unsafe) have no key and can never be renamed.
+func keyOf(fset *token.FileSet, obj types.Object) (objectKey, bool) {
+	if obj == nil || obj.Pkg() == nil || !obj.Pos().IsValid() {
+		return objectKey{}, false
+	}
+	pos := fset.Position(obj.Pos())
+	if pos.Filename == "" {
+		return objectKey{}, false
+	}
+	return objectKey{file: pos.Filename, line: pos.Line, col: pos.Column}, true
+}
+
+// Plan records the new name of every object that will be renamed, along
+// with any other edits the renames require (such as struct tags), and the
+// problems found while planning.
+type Plan struct {
+	// Conflicts are places where the renamed code would no longer compile
+	// or would mean something else.
+	Conflicts []Diagnostic
+	// Warnings are renames that were skipped or that may break code which
+	// refers to names at run time.
+	Warnings []Diagnostic
+
+	fset        *token.FileSet
+	pkgs        []*packages.Package
+	opts        *Options
+	names       map[objectKey]Rename
+	edits       map[string][]planEdit      // extra edits by file name
+	settings    map[string]*settings       // by config file name
+	conflictsOf map[objectKey][]Diagnostic // conflicts by renamed object
+	sources     map[string]*sourceFile     // by file name
+}
+
+// planEdit is an edit, beyond the renames themselves, that the rename of
+// the owner object requires.
+type planEdit struct {
+	owner objectKey
+	textEdit
+}
+
+func newPlan(fset *token.FileSet, pkgs []*packages.Package, opts *Options) *Plan {
+	return &Plan{
+		fset:        fset,
+		pkgs:        pkgs,
+		opts:        opts,
+		names:       map[objectKey]Rename{},
+		edits:       map[string][]planEdit{},
+		settings:    map[string]*settings{},
+		conflictsOf: map[objectKey][]Diagnostic{},
+		sources:     map[string]*sourceFile{},
+	}
+}
+
+// warnf records a warning at pos.
+func (p *Plan) warnf(pos token.Pos, format string, args ...any) {
+	p.Warnings = append(p.Warnings, Diagnostic{Pos: p.fset.Position(pos), Message: fmt.Sprintf(format, args...)})
+}
+
+// newName returns the planned name for obj, if it is being renamed.
+func (p *Plan) newName(obj types.Object) (string, bool) {
+	key, ok := p.renameKey(obj)
+	if !ok {
+		return "", false
+	}
+	r, ok := p.names[key]
+	return r.New, ok
+}
+
+// renameKey returns the key under which the rename of obj is planned. An
+// embedded field is named after its type, so it follows the type.
+func (p *Plan) renameKey(obj types.Object) (objectKey, bool) {
+	if v, ok := obj.(*types.Var); ok && v.Embedded() {
+		if tn := embeddedTypeName(v.Type()); tn != nil {
+			obj = tn
+		}
+	}
+	return keyOf(p.fset, obj)
+}
+
+func (p *Plan) set(obj types.Object, name string) {
+	if key, ok := keyOf(p.fset, obj); ok {
+		p.names[key] = Rename{Pos: p.fset.Position(obj.Pos()), Kind: objectKind(obj), Old: obj.Name(), New: name}
+	}
+}
+
+func (p *Plan) unset(obj types.Object) {
+	if key, ok := keyOf(p.fset, obj); ok {
+		delete(p.names, key)
+	}
+}
+
+// addEdit records an edit to filename that renaming owner requires.
+func (p *Plan) addEdit(owner types.Object, filename string, edit textEdit) {
+	if key, ok := keyOf(p.fset, owner); ok {
+		p.edits[filename] = append(p.edits[filename], planEdit{owner: key, textEdit: edit})
+	}
+}
+
+// embeddedTypeName returns the type name behind an embedded field's type.
+func embeddedTypeName(t types.Type) *types.TypeName {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	switch t := t.(type) {
+	case *types.Named:
+		return t.Obj()
+	case *types.Alias:
+		return t.Obj()
+	}
+	return nil
+}
+
+// planPackage adds to plan every object declared in pkg whose name does not
+// follow the naming rules of its settings.
+func planPackage(plan *Plan, pkg *packages.Package) error {
+	info := pkg.TypesInfo
+	thisPkg := pkg.Types
+
+	settings, err := plan.settingsFor(pkg)
+	if err != nil {
+		return err
+	}
+	ignoredFiles := map[string]bool{}
+	for _, file := range pkg.Syntax {
+		filename := pkg.Fset.File(file.Pos()).Name()
+		if settings.ignoresFile(filename, file) {
+			ignoredFiles[filename] = true
+		}
+	}
+
+	consider := func(obj types.Object) {
+		if obj == nil {
+			return
+		}
+		// Only objects that belong to *this* package
+		if obj.Pkg() == nil || obj.Pkg() != thisPkg {
+			return
+		}
+		// Optionally skip exported
+		if plan.opts.KeepExported && obj.Exported() {
+			return
+		}
+		// Skip package names
+		if _, isPkgName := obj.(*types.PkgName); isPkgName {
+			return
+		}
+		// Embedded fields are renamed along with their type
+		if v, ok := obj.(*types.Var); ok && v.Embedded() {
+			return
+		}
+		// Names fixed by the language or the go tool
+		if isSpecialFunc(obj) {
+			return
+		}
+		// Exempted by the config file
+		if settings.ignoresIdent(obj.Name()) || ignoredFiles[pkg.Fset.Position(obj.Pos()).Filename] {
+			return
+		}
+		old := obj.Name()
+		newName, ok := settings.overrides[old]
+		if !ok {
+			newName = settings.rules.convert(obj)
+		}
+		if newName == old || !isValidIdent(newName) {
+			return
+		}
+		plan.set(obj, newName)
+	}
+
+	// Collect candidates from Defs (declarations)
+	for ident, obj := range info.Defs {
+		if ident == nil || obj == nil {
+			continue
+		}
+		if ident.Name == "_" {
+			continue
+		}
+		consider(obj)
+	}
+	// Also consider objects that appear only in Uses
+	for _, obj := range info.Uses {
+		consider(obj)
+	}
+	// And the selected objects from selectors (fields/methods)
+	for _, sel := range info.Selections {
+		if sel == nil {
+			continue
+		}
+		consider(sel.Obj())
+	}
+	return nil
+}
+
+// planSingle plans the rename of the one object named by from, in the
+// syntax used by gorename: "pkg/path".Name or "pkg/path".Type.Member. The
+// quotes may be omitted when the package path is unambiguous.
+func planSingle(plan *Plan, from, to string) error {
+	if from == "" || to == "" {
+		return fmt.Errorf("from and to must be given together")
+	}
+	if !isValidIdent(to) {
+		return fmt.Errorf("-to %q is not a valid identifier", to)
+	}
+	pkgPath, member, err := parseFrom(from)
+	if err != nil {
+		return err
+	}
+	var pkg *types.Package
+	packages.Visit(plan.pkgs, nil, func(p *packages.Package) {
+		if pkg == nil && p.PkgPath == pkgPath && p.Types != nil {
+			pkg = p.Types
+		}
+	})
+	if pkg == nil {
+		return fmt.Errorf("-from %s: package %q not loaded", from, pkgPath)
+	}
+
+	names := strings.Split(member, ".")
+	if len(names) > 2 {
+		return fmt.Errorf("-from %s: want Name or Type.Member after the package path", from)
+	}
+	obj := pkg.Scope().Lookup(names[0])
+	if obj == nil {
+		return fmt.Errorf("-from %s: %s not declared in package %q", from, names[0], pkgPath)
+	}
+	if len(names) == 2 {
+		tn, ok := obj.(*types.TypeName)
+		if !ok {
+			return fmt.Errorf("-from %s: %s is not a type", from, names[0])
+		}
+		obj, _, _ = types.LookupFieldOrMethod(tn.Type(), true, pkg, names[1])
+		if obj == nil {
+			return fmt.Errorf("-from %s: type %s has no field or method %s", from, names[0], names[1])
+		}
+	}
+	if _, ok := keyOf(plan.fset, obj); !ok {
+		return fmt.Errorf("-from %s: object has no source position", from)
+	}
+	plan.set(obj, to)
+	return nil
+}
+
+// parseFrom splits a -from spec into its package path and member.
+func parseFrom(from string) (pkgPath, member string, err error) {
+	if strings.HasPrefix(from, `"`) {
+		end := strings.Index(from[1:], `"`)
+		if end < 0 || !strings.HasPrefix(from[end+2:], ".") {
+			return "", "", fmt.Errorf(`-from %s: want "pkg/path".Name`, from)
+		}
+		pkgPath, err = strconv.Unquote(from[:end+2])
+		return pkgPath, from[end+3:], err
+	}
+	slash := strings.LastIndex(from, "/")
+	dot := strings.Index(from[slash+1:], ".")
+	if dot < 0 {
+		return "", "", fmt.Errorf("-from %s: want pkg/path.Name", from)
+	}
+	dot += slash + 1
+	return from[:dot], from[dot+1:], nil
+}
@@ -0,0 +1,262 @@
+// Package rename renames Go identifiers to follow a naming convention, such
+// as snake_case, across one package or a whole module tree.
+//
+// It works in three steps. Load type-checks the packages to rewrite.
+// PlanRenames decides the new name of every identifier and checks that the
+// result still compiles and means the same thing, recording conflicts and
+// warnings. Apply turns the plan into new file contents, changing only the
+// renamed tokens, and returns them in a Report along with the renames made.
+//
+// Analyzer offers the same check to go vet, gopls and golangci-lint, with a
+// suggested fix for each unexported identifier.
+package rename
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Options control how identifiers are renamed. The zero value renames every
+// identifier to snake_case, as the original camel_not_cased tool did.
+type Options struct {
+	// Style is the default naming style. If empty, the style from the
+	// config file is used, or snake_case if there is none.
+	Style string
+	// Rules are per-kind styles, e.g. "const=SCREAMING_SNAKE,local=snake".
+	// They take precedence over the rules of the config file.
+	Rules string
+	// Config is a config file to use for every package instead of looking
+	// for .camelnotcased.yaml in each package directory and its parents.
+	Config string
+	// KeepExported leaves exported identifiers alone.
+	KeepExported bool
+
+	// From and To, when set, rename only the identifier named by From
+	// ("pkg/path".Name or "pkg/path".Type.Member) to To. To without From
+	// names the target style instead, as in To: "mixedcaps", and is an
+	// alternative to Style.
+	From, To string
+
+	// Module makes Load treat its arguments as directories, and load every
+	// module found below them in full, so that importers are updated too.
+	Module bool
+	// Variants makes Load also load the packages under every GOOS, GOARCH
+	// and build tag combination their files declare, so that files for
+	// other platforms are renamed consistently with the rest.
+	Variants bool
+
+	// FieldTags says how to protect the wire names of renamed struct
+	// fields: "add" (the default) inserts a tag with the old name, "skip"
+	// leaves fields without an explicit name alone, and "off" does nothing.
+	FieldTags string
+	// TagKeys are the struct tag keys to protect. If empty, they are
+	// detected from the encoding packages the loaded packages import, and
+	// a field whose struct reaches calls into some of them only gets
+	// their keys.
+	TagKeys []string
+	// FieldTagsReached, with detected keys, leaves out the fields of
+	// structs that reach no call into an encoding package in the loaded
+	// packages, with a warning, instead of protecting them with every key.
+	FieldTagsReached bool
+
+	// Comments also renames mentions of renamed identifiers in comments:
+	// words that denote a renamed object in the scope of the comment, and
+	// the name of a renamed field or method in its own doc comment.
+	Comments bool
+	// Strings also renames such mentions in string literals marked with a
+	// StringsDirective comment. Unmarked literals are never changed.
+	Strings bool
+	// Format gofmts the rewritten files.
+	Format bool
+	// Force applies the plan even if it has conflicts.
+	Force bool
+
+	// Log, if not nil, receives progress messages such as packages skipped
+	// while loading.
+	Log io.Writer
+}
+
+func (o *Options) logf(format string, args ...any) {
+	if o.Log != nil {
+		fmt.Fprintf(o.Log, format+"\n", args...)
+	}
+}
+
+// style returns the style asked for by Style or by To without From.
+func (o *Options) style() string {
+	if o.From == "" && o.To != "" {
+		return o.To
+	}
+	return o.Style
+}
+
+// Validate checks the options for errors that do not depend on the
+// packages being renamed.
+func (o *Options) Validate() error {
+	if o.From != "" && o.To == "" {
+		return errors.New("from needs to")
+	}
+	if o.From == "" && o.To != "" && o.Style != "" {
+		return errors.New("to without from selects the style; do not also give style")
+	}
+	style := styleSnake
+	if name := o.style(); name != "" {
+		s, err := parseStyle(name)
+		if err != nil {
+			return fmt.Errorf("style: %v", err)
+		}
+		style = s
+	}
+	if _, err := parseRules(style, o.Rules); err != nil {
+		return fmt.Errorf("rules: %v", err)
+	}
+	switch o.FieldTags {
+	case "", "add", "skip", "off":
+	default:
+		return fmt.Errorf("field tags: want add, skip or off, got %q", o.FieldTags)
+	}
+	return nil
+}
+
+// Diagnostic is a problem found at a position in the source.
+type Diagnostic struct {
+	Pos     token.Position
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Pos, d.Message)
+}
+
+// Rename is one object that the plan renames.
+type Rename struct {
+	Pos      token.Position // declaration of the object
+	Kind     string         // const, var, local, func, method, type, field or label
+	Old, New string
+}
+
+func (r Rename) String() string {
+	return fmt.Sprintf("%s: %s %s -> %s", r.Pos, r.Kind, r.Old, r.New)
+}
+
+// FileChange is the rewritten content of one source file.
+type FileChange struct {
+	Filename string
+	Src, Out []byte
+}
+
+// Changed reports whether the file content differs from the original.
+func (c FileChange) Changed() bool {
+	return !bytes.Equal(c.Src, c.Out)
+}
+
+// Diff returns a unified diff of the change, or "" if there is none.
+func (c FileChange) Diff() string {
+	return unifiedDiff(c.Filename, c.Src, c.Out)
+}
+
+// Write replaces the file with the new content.
+func (c FileChange) Write() error {
+	return os.WriteFile(c.Filename, c.Out, 0o666)
+}
+
+// Report is the outcome of applying a plan.
+type Report struct {
+	Changes       []FileChange // one per rewritten file, changed or not
+	Renames       []Rename
+	Substitutions []Substitution // in comments and string literals
+	Conflicts     []Diagnostic
+	Warnings      []Diagnostic
+}
+
+// ErrConflicts is returned by Apply when the plan has conflicts and
+// Options.Force is not set.
+var ErrConflicts = errors.New("renaming would introduce conflicts")
+
+// PlanRenames decides the new names for the identifiers in pkgs and checks
+// the result for conflicts.
+func PlanRenames(pkgs []*packages.Package, opts *Options) (*Plan, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if len(pkgs) == 0 {
+		return nil, errors.New("no packages to rename")
+	}
+
+	plan := newPlan(pkgs[0].Fset, pkgs, opts)
+	if opts.From != "" {
+		if err := planSingle(plan, opts.From, opts.To); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, pkg := range pkgs {
+			if err := planPackage(plan, pkg); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	unifyMethods(plan)
+	if opts.From == "" {
+		keepExports(plan)
+	}
+	protectFields(plan)
+	reportReflection(plan)
+	plan.Conflicts = checkConflicts(plan)
+	// Variants of a package report the same problems.
+	plan.Warnings = uniqueDiagnostics(plan.Warnings)
+	return plan, nil
+}
+
+// Renames returns the renames in the plan, sorted by position.
+func (p *Plan) Renames() []Rename {
+	renames := make([]Rename, 0, len(p.names))
+	for _, r := range p.names {
+		if r.Old != r.New {
+			renames = append(renames, r)
+		}
+	}
+	sort.Slice(renames, func(i, j int) bool { return positionLess(renames[i].Pos, renames[j].Pos) })
+	return renames
+}
+
+func positionLess(a, b token.Position) bool {
+	if a.Filename != b.Filename {
+		return a.Filename < b.Filename
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
+
+// uniqueDiagnostics sorts diags and drops repeated ones.
+func uniqueDiagnostics(diags []Diagnostic) []Diagnostic {
+	sortDiagnostics(diags)
+	out := diags[:0]
+	for i, d := range diags {
+		if i == 0 || d != diags[i-1] {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func sortDiagnostics(diags []Diagnostic) {
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].Pos != diags[j].Pos {
+			return positionLess(diags[i].Pos, diags[j].Pos)
+		}
+		return diags[i].Message < diags[j].Message
+	})
+}
@@ -0,0 +1,293 @@
+package rename
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files in testdata")
+
+// Each case is a directory under testdata holding one or more modules. The
+// expected content of every rewritten file is in a .golden file next to it,
+// the expected warnings and conflicts are in diagnostics.golden, and the
+// substitutions made in comments and strings are in substitutions.golden.
+var goldenCases = []struct {
+	dir  string
+	opts Options
+}{
+	{dir: "styles"},
+	{dir: "rules", opts: Options{Rules: "const=SCREAMING_SNAKE,exported-func=Capitalized_snake,type=PascalCase"}},
+	{dir: "crosspkg", opts: Options{Style: "Capitalized_snake", Comments: true}},
+	{dir: "crossdefault"},
+	{dir: "conflicts"},
+	{dir: "tags", opts: Options{Style: "Capitalized_snake"}},
+	{dir: "interfaces"},
+	{dir: "config"},
+	{dir: "variants", opts: Options{Variants: true}},
+	{dir: "mixedcaps", opts: Options{To: "mixedcaps"}},
+	{dir: "comments", opts: Options{KeepExported: true, Comments: true, Strings: true}},
+}
+
+func TestGolden(t *testing.T) {
+	for _, tc := range goldenCases {
+		t.Run(tc.dir, func(t *testing.T) {
+			root, err := filepath.Abs(filepath.Join("testdata", tc.dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts := tc.opts
+			opts.Module = true
+
+			fset := token.NewFileSet()
+			pkgs, err := Load(fset, []string{root}, &opts)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			plan, err := PlanRenames(pkgs, &opts)
+			if err != nil {
+				t.Fatalf("PlanRenames: %v", err)
+			}
+
+			var diags bytes.Buffer
+			for _, d := range plan.Warnings {
+				fmt.Fprintf(&diags, "warning: %s\n", relative(root, d))
+			}
+			for _, d := range plan.Conflicts {
+				fmt.Fprintf(&diags, "conflict: %s\n", relative(root, d))
+			}
+			checkGolden(t, filepath.Join(root, "diagnostics.golden"), diags.Bytes())
+
+			report, err := Apply(plan)
+			if len(plan.Conflicts) > 0 {
+				if !errors.Is(err, ErrConflicts) {
+					t.Errorf("Apply with conflicts: got %v, want ErrConflicts", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if err := Verify([]string{root}, plan, report); err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+			var subs bytes.Buffer
+			for _, s := range report.Substitutions {
+				fmt.Fprintf(&subs, "%s\n", strings.ReplaceAll(s.String(), root+string(filepath.Separator), ""))
+			}
+			checkGolden(t, filepath.Join(root, "substitutions.golden"), subs.Bytes())
+
+			for _, c := range report.Changes {
+				if c.Changed() {
+					checkGolden(t, c.Filename+".golden", c.Out)
+				} else if _, err := os.Stat(c.Filename + ".golden"); err == nil {
+					t.Errorf("%s: unchanged, but has a .golden file", c.Filename)
+				}
+			}
+		})
+	}
+}
+
+// relative formats d with its file name relative to root, so that golden
+// files do not depend on where the repository is checked out.
+func relative(root string, d Diagnostic) string {
+	s := d.String()
+	return strings.ReplaceAll(s, root+string(filepath.Separator), "")
+}
+
+func checkGolden(t *testing.T, golden string, got []byte) {
+	t.Helper()
+	if *update {
+		if len(got) == 0 {
+			os.Remove(golden)
+			return
+		}
+		if err := os.WriteFile(golden, got, 0o666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\n%s", filepath.Base(golden), unifiedDiff(golden, want, got))
+	}
+}
+
+func TestVerifyRejectsBrokenCode(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("testdata", "redeclare"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &Options{Module: true, Force: true}
+	pkgs, err := Load(token.NewFileSet(), []string{root}, opts)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	plan, err := PlanRenames(pkgs, opts)
+	if err != nil {
+		t.Fatalf("PlanRenames: %v", err)
+	}
+	if len(plan.Conflicts) == 0 {
+		t.Fatal("want a conflict")
+	}
+	report, err := Apply(plan)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	err = Verify([]string{root}, plan, report)
+	if err == nil || !strings.Contains(err.Error(), "redeclared") {
+		t.Errorf("Verify: got %v, want a redeclaration error", err)
+	}
+}
+
+func TestVerifyChecksImporters(t *testing.T) {
+	t.Chdir(filepath.Join("testdata", "importer"))
+	// Without Module only lib is loaded, so app keeps the old name.
+	lib := []string{"./lib"}
+	opts := &Options{}
+	pkgs, err := Load(token.NewFileSet(), lib, opts)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	plan, err := PlanRenames(pkgs, opts)
+	if err != nil {
+		t.Fatalf("PlanRenames: %v", err)
+	}
+	report, err := Apply(plan)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	err = Verify(lib, plan, report)
+	if err == nil || !strings.Contains(err.Error(), "example.com/importer/app was not loaded") {
+		t.Errorf("Verify: got %v, want an error for the importer", err)
+	}
+}
+
+// copyCase copies the source files of a testdata case, without its golden
+// files, to a temporary directory and returns it.
+func copyCase(t *testing.T, name string) string {
+	t.Helper()
+	src := filepath.Join("testdata", name)
+	dst := t.TempDir()
+	err := filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(path, ".golden") {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Join(dst, filepath.Dir(rel)), 0o777); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), data, 0o666)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dst
+}
+
+// TestDefaultStyleBuilds renames a copy of the crossdefault case in the
+// default style, writes it and builds the importing module.
+func TestDefaultStyleBuilds(t *testing.T) {
+	root := copyCase(t, "crossdefault")
+	opts := &Options{Module: true}
+	pkgs, err := Load(token.NewFileSet(), []string{root}, opts)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	plan, err := PlanRenames(pkgs, opts)
+	if err != nil {
+		t.Fatalf("PlanRenames: %v", err)
+	}
+	report, err := Apply(plan)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	for _, c := range report.Changes {
+		if err := c.Write(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command("go", "build", "./...")
+	cmd.Dir = filepath.Join(root, "app")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("go build: %v\n%s", err, out)
+	}
+}
+
+func TestExportChangeConflicts(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("testdata", "crossdefault"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &Options{Module: true, From: `"example.com/lib/util".GetFileSize`, To: "get_file_size"}
+	pkgs, err := Load(token.NewFileSet(), []string{root}, opts)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	plan, err := PlanRenames(pkgs, opts)
+	if err != nil {
+		t.Fatalf("PlanRenames: %v", err)
+	}
+	want := "app/main.go:11:19: renaming GetFileSize to get_file_size would make it unexported, but package example.com/app refers to it here"
+	if len(plan.Conflicts) != 1 || relative(root, plan.Conflicts[0]) != want {
+		t.Errorf("conflicts: got %v, want %s", plan.Conflicts, want)
+	}
+}
+
+func TestFieldTagsReached(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("testdata", "tags"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &Options{Module: true, Style: "Capitalized_snake", FieldTagsReached: true}
+	pkgs, err := Load(token.NewFileSet(), []string{root}, opts)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	plan, err := PlanRenames(pkgs, opts)
+	if err != nil {
+		t.Fatalf("PlanRenames: %v", err)
+	}
+	var warned []string
+	for _, w := range plan.Warnings {
+		if strings.Contains(w.Message, "reaches no encoder call") {
+			warned = append(warned, relative(root, w))
+		}
+	}
+	want := []string{
+		"a.go:34:2: renaming field DryRun without a tag: its struct reaches no encoder call in the loaded packages",
+		"a.go:39:2: renaming field ItemCount without a tag: its struct reaches no encoder call in the loaded packages",
+	}
+	if !slices.Equal(warned, want) {
+		t.Errorf("warnings:\ngot  %q\nwant %q", warned, want)
+	}
+
+	report, err := Apply(plan)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	out := string(report.Changes[0].Out)
+	for _, tag := range []string{"`json:\"LogLevel\"`", "Dry_run bool\n", "Item_count int\n"} {
+		if !strings.Contains(out, tag) {
+			t.Errorf("renamed a.go does not contain %q", tag)
+		}
+	}
+}
@@ -0,0 +1,467 @@
+package rename
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// tagKeyByImport maps encoding packages to the struct tag key they read.
+var tagKeyByImport = map[string]string{
+	"encoding/json":                   "json",
+	"encoding/xml":                    "xml",
+	"gopkg.in/yaml.v2":                "yaml",
+	"gopkg.in/yaml.v3":                "yaml",
+	"github.com/goccy/go-yaml":        "yaml",
+	"sigs.k8s.io/yaml":                "json",
+	"github.com/BurntSushi/toml":      "toml",
+	"github.com/pelletier/go-toml":    "toml",
+	"github.com/pelletier/go-toml/v2": "toml",
+}
+
+// tagKeys returns the struct tag keys to protect: those given explicitly,
+// or else those of every encoding package imported by a loaded package.
+func tagKeys(pkgs []*packages.Package, explicit []string) []string {
+	set := map[string]bool{}
+	if len(explicit) > 0 {
+		for _, key := range explicit {
+			if key = strings.TrimSpace(key); key != "" {
+				set[key] = true
+			}
+		}
+	} else {
+		packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+			for path := range pkg.Imports {
+				if key, ok := tagKeyByImport[path]; ok {
+					set[key] = true
+				}
+			}
+		})
+	}
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// wireName is the name an encoder uses for a field without an explicit name
+// in its tag.
+func wireName(key, field string) string {
+	if key == "yaml" {
+		return strings.ToLower(field)
+	}
+	return field
+}
+
+// protectFields keeps renamed struct fields marshalling to the same keys.
+// In "add" mode (Options.FieldTags) it plans an edit that adds the old wire name to
+// each renamed field's tag; in skip mode it drops the rename of any field
+// whose wire name would change. Fields that would become unexported cannot
+// be protected by a tag and are never renamed unless the mode is off.
+//
+// When the tag keys are detected rather than given, a field of a struct
+// type that reaches calls into encoding packages is given the keys of
+// those packages only, and any other field every key detected, since it
+// may be marshalled where the calls cannot be seen. With
+// Options.FieldTagsReached those other fields are renamed as they are,
+// with a warning.
+func protectFields(plan *Plan) {
+	mode := plan.opts.FieldTags
+	if mode == "" {
+		mode = "add"
+	}
+	if mode == "off" {
+		return
+	}
+	keys := tagKeys(plan.pkgs, plan.opts.TagKeys)
+	if len(keys) == 0 {
+		return
+	}
+	var marshalled map[string][]string // nil gives every field every key
+	if len(plan.opts.TagKeys) == 0 {
+		marshalled = marshalledFields(plan.pkgs)
+	}
+
+	done := map[string]bool{}
+	for _, pkg := range plan.pkgs {
+		for _, file := range pkg.Syntax {
+			tokFile := pkg.Fset.File(file.Pos())
+			if done[tokFile.Name()] {
+				continue
+			}
+			done[tokFile.Name()] = true
+
+			ast.Inspect(file, func(n ast.Node) bool {
+				st, ok := n.(*ast.StructType)
+				if !ok {
+					return true
+				}
+				for _, field := range st.Fields.List {
+					protectField(plan, pkg, tokFile, field, keys, mode, marshalled)
+				}
+				return true
+			})
+		}
+	}
+}
+
+// marshalledFields returns the tag keys of the encoding packages each
+// field reaches, by position as fieldKey makes it. A field reaches a call
+// into an encoding package in pkgs when its struct type does: as the type
+// of an argument, or in turn as the type of a field, element or value
+// pointed to.
+func marshalledFields(pkgs []*packages.Package) map[string][]string {
+	fields := map[string][]string{}
+	type typeKey struct {
+		t   types.Type
+		key string
+	}
+	seen := map[typeKey]bool{}
+	var reach func(fset *token.FileSet, t types.Type, key string)
+	reach = func(fset *token.FileSet, t types.Type, key string) {
+		if t == nil || seen[typeKey{t, key}] {
+			return
+		}
+		seen[typeKey{t, key}] = true
+		switch t := t.(type) {
+		case *types.Alias:
+			reach(fset, types.Unalias(t), key)
+		case *types.Named:
+			reach(fset, t.Underlying(), key)
+		case *types.Pointer:
+			reach(fset, t.Elem(), key)
+		case *types.Slice:
+			reach(fset, t.Elem(), key)
+		case *types.Array:
+			reach(fset, t.Elem(), key)
+		case *types.Map:
+			reach(fset, t.Key(), key)
+			reach(fset, t.Elem(), key)
+		case *types.Struct:
+			for i := range t.NumFields() {
+				f := t.Field(i)
+				name := fieldKey(fset, f.Origin())
+				if !slices.Contains(fields[name], key) {
+					fields[name] = append(fields[name], key)
+				}
+				reach(fset, f.Type(), key)
+			}
+		}
+	}
+
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if pkg.TypesInfo == nil {
+			return
+		}
+		for _, file := range pkg.Syntax {
+			ast.Inspect(file, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				var id *ast.Ident
+				switch fun := ast.Unparen(call.Fun).(type) {
+				case *ast.SelectorExpr:
+					id = fun.Sel
+				case *ast.Ident:
+					id = fun
+				}
+				if id == nil {
+					return true
+				}
+				// Methods count too, as (*json.Decoder).Decode does.
+				fn, ok := pkg.TypesInfo.Uses[id].(*types.Func)
+				if !ok || fn.Pkg() == nil {
+					return true
+				}
+				key, ok := tagKeyByImport[fn.Pkg().Path()]
+				if !ok {
+					return true
+				}
+				for _, arg := range call.Args {
+					reach(pkg.Fset, pkg.TypesInfo.TypeOf(arg), key)
+				}
+				return true
+			})
+		}
+	})
+	return fields
+}
+
+// fieldKey identifies a field by where it is declared, the same in every
+// build variant of its package.
+func fieldKey(fset *token.FileSet, field *types.Var) string {
+	pos := fset.Position(field.Pos())
+	return pos.Filename + ":" + strconv.Itoa(pos.Offset)
+}
+
+func protectField(plan *Plan, pkg *packages.Package, tokFile *token.File, field *ast.Field, keys []string, mode string, marshalled map[string][]string) {
+	var renamed []types.Object
+	for _, name := range field.Names {
+		if obj := pkg.TypesInfo.Defs[name]; obj != nil && obj.Exported() {
+			if newName, ok := plan.newName(obj); ok && newName != obj.Name() {
+				renamed = append(renamed, obj)
+			}
+		}
+	}
+	if len(renamed) == 0 {
+		return
+	}
+	// encoding/xml finds the element name through a field called XMLName.
+	if renamed[0].Name() == "XMLName" {
+		plan.unset(renamed[0])
+		plan.warnf(renamed[0].Pos(), "not renaming field XMLName: encoding/xml looks it up by name")
+		return
+	}
+
+	if marshalled != nil {
+		if used := marshalled[fieldKey(pkg.Fset, renamed[0].(*types.Var))]; len(used) > 0 {
+			keys = slices.DeleteFunc(slices.Clone(keys), func(key string) bool { return !slices.Contains(used, key) })
+		} else if plan.opts.FieldTagsReached {
+			for _, obj := range renamed {
+				plan.warnf(obj.Pos(), "renaming field %s without a tag: its struct reaches no encoder call in the loaded packages",
+					obj.Name())
+			}
+			return
+		}
+	}
+
+	tag := ""
+	if field.Tag != nil {
+		tag, _ = strconv.Unquote(field.Tag.Value)
+	}
+	pairs := parseTag(tag)
+	var missing []string
+	for _, key := range keys {
+		if name, _, _ := strings.Cut(lookupTag(pairs, key), ","); name == "" {
+			missing = append(missing, key)
+		}
+	}
+
+	// Encoders skip unexported fields, explicit name or not.
+	var kept []types.Object
+	for _, obj := range renamed {
+		if newName, _ := plan.newName(obj); !token.IsExported(newName) {
+			plan.unset(obj)
+			plan.warnf(obj.Pos(), "not renaming field %s to %s: unexported fields are not marshalled (%s)",
+				obj.Name(), newName, strings.Join(keys, ", "))
+			continue
+		}
+		kept = append(kept, obj)
+	}
+	renamed = kept
+	if len(renamed) == 0 || len(missing) == 0 {
+		return // every encoder already has an explicit name
+	}
+
+	for _, obj := range renamed {
+		switch {
+		case mode == "skip":
+			plan.unset(obj)
+			plan.warnf(obj.Pos(), "not renaming field %s: it has no explicit %s name",
+				obj.Name(), strings.Join(missing, "/"))
+		case len(field.Names) > 1:
+			plan.unset(obj)
+			plan.warnf(obj.Pos(), "not renaming field %s: it shares its declaration, and tag, with other fields",
+				obj.Name())
+		}
+	}
+	if mode != "add" || len(field.Names) != 1 {
+		return
+	}
+	obj := renamed[0]
+	if newName, ok := plan.newName(obj); !ok || !token.IsExported(newName) {
+		return
+	}
+
+	for _, key := range missing {
+		value := wireName(key, obj.Name())
+		if _, opts, found := strings.Cut(lookupTag(pairs, key), ","); found {
+			value += "," + opts
+		}
+		pairs = setTag(pairs, key, value)
+	}
+	newTag := formatTag(pairs)
+
+	filename := tokFile.Name()
+	var edit textEdit
+	switch {
+	case !isSourceFile(pkg, filename):
+		// cgo rewrote the file; a tag is copied as is, but the end of a
+		// type such as C.int cannot be found in the source.
+		if field.Tag == nil {
+			plan.unset(obj)
+			plan.warnf(obj.Pos(), "not renaming field %s: cannot add a tag in a file that uses cgo", obj.Name())
+			return
+		}
+		name, start, ok := plan.cgoOffset(field.Tag.Pos())
+		if !ok {
+			plan.unset(obj)
+			return
+		}
+		filename = name
+		edit = textEdit{start: start, end: start + len(field.Tag.Value), text: quoteTag(newTag)}
+	case field.Tag != nil:
+		edit = textEdit{start: tokFile.Offset(field.Tag.Pos()), end: tokFile.Offset(field.Tag.End())}
+		edit.text = quoteTag(newTag)
+	default:
+		end := tokFile.Offset(field.Type.End())
+		edit = textEdit{start: end, end: end, text: " " + quoteTag(newTag)}
+	}
+	plan.addEdit(obj, filename, edit)
+}
+
+// tagPair is one key:"value" element of a struct tag.
+type tagPair struct {
+	key, value string
+}
+
+// parseTag splits a struct tag into its pairs, following the conventional
+// format understood by reflect.StructTag.
+func parseTag(tag string) []tagPair {
+	var pairs []tagPair
+	for tag != "" {
+		tag = strings.TrimLeft(tag, " ")
+		i := 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			break
+		}
+		key := tag[:i]
+		tag = tag[i+1:]
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			break
+		}
+		value, err := strconv.Unquote(tag[:i+1])
+		if err != nil {
+			break
+		}
+		pairs = append(pairs, tagPair{key, value})
+		tag = tag[i+1:]
+	}
+	return pairs
+}
+
+func lookupTag(pairs []tagPair, key string) string {
+	for _, p := range pairs {
+		if p.key == key {
+			return p.value
+		}
+	}
+	return ""
+}
+
+func setTag(pairs []tagPair, key, value string) []tagPair {
+	for i, p := range pairs {
+		if p.key == key {
+			pairs[i].value = value
+			return pairs
+		}
+	}
+	return append(pairs, tagPair{key, value})
+}
+
+func formatTag(pairs []tagPair) string {
+	parts := make([]string, len(pairs))
+	for i, p := range pairs {
+		parts[i] = p.key + ":" + strconv.Quote(p.value)
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteTag returns tag as a Go literal, raw if possible.
+func quoteTag(tag string) string {
+	if strconv.CanBackquote(tag) {
+		return "`" + tag + "`"
+	}
+	return strconv.Quote(tag)
+}
+
+// templateField matches a field or method reference such as .Name in a
+// text/template or html/template action.
+var templateField = regexp.MustCompile(`\.([\pL_][\pL\pN_]*)`)
+
+// reportReflection warns about renamed fields and methods that are looked
+// up by name at run time, through reflect or text/template, where the
+// rename cannot follow them.
+func reportReflection(plan *Plan) {
+	pkgs := plan.pkgs
+	// Old and new names of renamed fields and methods.
+	renamedMembers := map[string]string{}
+	for _, pkg := range pkgs {
+		for _, obj := range pkg.TypesInfo.Defs {
+			if obj == nil || objectKind(obj) != "field" && objectKind(obj) != "method" {
+				continue
+			}
+			if newName, ok := plan.newName(obj); ok && newName != obj.Name() {
+				renamedMembers[obj.Name()] = newName
+			}
+		}
+	}
+	if len(renamedMembers) == 0 {
+		return
+	}
+
+	seen := map[token.Pos]bool{}
+	for _, pkg := range pkgs {
+		info := pkg.TypesInfo
+		for _, file := range pkg.Syntax {
+			ast.Inspect(file, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok || len(call.Args) == 0 || seen[call.Pos()] {
+					return true
+				}
+				sel, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				fn, ok := info.Uses[sel.Sel].(*types.Func)
+				if !ok || fn.Pkg() == nil {
+					return true
+				}
+				arg := info.Types[call.Args[0]].Value
+				if arg == nil || arg.Kind() != constant.String {
+					return true
+				}
+				text := constant.StringVal(arg)
+
+				switch path := fn.Pkg().Path(); {
+				case path == "reflect" && strings.HasSuffix(fn.Name(), "ByName"):
+					if newName, ok := renamedMembers[text]; ok {
+						seen[call.Pos()] = true
+						plan.warnf(call.Pos(), "reflect %s(%q) refers to a name being renamed to %s",
+							fn.Name(), text, newName)
+					}
+				case (path == "text/template" || path == "html/template") && fn.Name() == "Parse":
+					for _, m := range templateField.FindAllStringSubmatch(text, -1) {
+						if newName, ok := renamedMembers[m[1]]; ok {
+							seen[call.Pos()] = true
+							plan.warnf(call.Pos(), "template uses .%s, which is being renamed to %s",
+								m[1], newName)
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+}
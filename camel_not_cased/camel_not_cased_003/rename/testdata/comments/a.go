@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// maxRetryCount bounds the retries of fetchPage.
+const maxRetryCount = 3
+
+// Server serves pages.
+type Server struct {
+	// BaseURL is prepended to every path; see Server.fetchPage.
+	BaseURL  string
+	pageSize int // pageSize is the number of items per page
+}
+
+// fetchPage fetches one page, trying at most maxRetryCount times.
+func (s *Server) fetchPage(pathName string) string {
+	// Join s.BaseURL and pathName.
+	fullPath := s.BaseURL + pathName
+	return strings.TrimSpace(fullPath)
+}
+
+func summary() {
+	// itemCount counts the summary lines.
+	itemCount := 1
+	fmt.Println("itemCount", itemCount)
+}
+
+func render(s *Server) {
+	itemCount := s.pageSize
+	// Print itemCount; the string below is marked for renaming.
+	//camelnotcased:strings
+	t := template.Must(template.New("page").Parse("{{.BaseURL}} itemCount\n"))
+	t.Execute(nil, s)
+	fmt.Println("itemCount", itemCount) // unmarked: left alone
+}
+
+func main() {
+	summary()
+	render(&Server{})
+}
@@ -0,0 +1,7 @@
+package sub
+
+const maxRetry = 1
+
+var IPv6Addr, OAuth2Token, fooBar, keepMe string
+
+func useGen() int { return genValue }
@@ -0,0 +1,3 @@
+package sub
+
+var genValue = len(IPv6Addr)
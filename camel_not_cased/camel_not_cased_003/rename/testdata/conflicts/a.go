@@ -0,0 +1,46 @@
+package main
+
+import "fmt"
+
+var foo_bar = 1
+
+type base struct{ user_name string }
+
+func (base) do_it() {}
+
+type derived struct {
+	base
+	userName string
+}
+
+func (derived) doIt() {}
+
+func f(fooBar int) int {
+	x := foo_bar
+	return fooBar + x
+}
+
+func g() {
+	total := 1
+	{
+		itemCount := 2
+		fmt.Println(total, itemCount)
+	}
+	{
+		fooBar := 3
+		_ = fooBar
+		{
+			foo_bar := 4
+			fmt.Println(fooBar, foo_bar)
+		}
+	}
+	lenOf := 3
+	_ = lenOf
+}
+
+func h() {
+	fMt := 1
+	fmt.Println(fMt)
+}
+
+func main() { fmt.Println(f(1)); g(); h(); _ = derived{} }
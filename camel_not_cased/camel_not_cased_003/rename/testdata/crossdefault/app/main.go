@@ -0,0 +1,13 @@
+package main
+
+import (
+	"fmt"
+
+	"example.com/lib/util"
+)
+
+func main() {
+	treeWalker := util.Walker{MaxDepth: 2}
+	fileSize := util.GetFileSize(3)
+	fmt.Println(fileSize, treeWalker.WalkTree())
+}
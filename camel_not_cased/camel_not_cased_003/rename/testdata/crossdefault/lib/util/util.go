@@ -0,0 +1,21 @@
+package util
+
+// GetFileSize returns n, or 0 if it is negative.
+func GetFileSize(n int) int { return clampSize(n) }
+
+// DefaultDepth is not used outside this package.
+const DefaultDepth = 8
+
+func clampSize(n int) int { return max(n, 0) }
+
+type Walker struct {
+	MaxDepth   int
+	skipHidden bool
+}
+
+func (w Walker) WalkTree() int {
+	if w.skipHidden {
+		return DefaultDepth
+	}
+	return w.MaxDepth
+}
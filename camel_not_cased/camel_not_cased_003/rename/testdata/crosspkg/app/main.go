@@ -0,0 +1,12 @@
+package main
+
+import (
+	"fmt"
+
+	"example.com/lib/util"
+)
+
+func main() {
+	w := util.Walker{MaxDepth: 2}
+	fmt.Println(util.GetFileSize(3), w.WalkTree())
+}
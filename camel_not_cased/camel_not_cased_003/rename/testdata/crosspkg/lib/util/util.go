@@ -0,0 +1,8 @@
+package util
+
+// GetFileSize returns n.
+func GetFileSize(n int) int { return n }
+
+type Walker struct{ MaxDepth int }
+
+func (w Walker) WalkTree() int { return w.MaxDepth }
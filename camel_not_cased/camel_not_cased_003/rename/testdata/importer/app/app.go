@@ -0,0 +1,5 @@
+package app
+
+import "example.com/importer/lib"
+
+var Name = lib.Open_file("a")
@@ -0,0 +1,3 @@
+package lib
+
+func Open_file(name string) string { return name }
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+type Shape interface {
+	AreaOf() float64
+}
+
+type square struct{ side float64 }
+
+func (s square) AreaOf() float64 { return s.side * s.side }
+func (s square) String() string  { return "square" }
+
+type myErr struct{}
+
+func (myErr) Error() string { return "x" }
+
+type sink struct{}
+
+func (sink) Write(p []byte) (int, error) { return len(p), nil }
+
+var _ io.Writer = sink{}
+
+func main() {
+	var s Shape = square{2}
+	fmt.Println(s.AreaOf(), square{1}, myErr{})
+}
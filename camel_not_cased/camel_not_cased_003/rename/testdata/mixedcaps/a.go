@@ -0,0 +1,18 @@
+package main
+
+import "fmt"
+
+const Max_retry_count = 3
+
+type Http_server struct{ listen_url string }
+
+func Get_file_size(file_path string) int { return len(file_path) }
+
+func (s *Http_server) start_now() string { return s.listen_url }
+
+func get_user_id() int { return 1 }
+
+func main() {
+	local_value := Get_file_size("x")
+	fmt.Println(local_value, Max_retry_count, get_user_id())
+}
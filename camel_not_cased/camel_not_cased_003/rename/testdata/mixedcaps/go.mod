@@ -0,0 +1,3 @@
+module example.com/mixedcaps
+
+go 1.24
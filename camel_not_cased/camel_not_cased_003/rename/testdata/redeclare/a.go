@@ -0,0 +1,5 @@
+package main
+
+var fooBar, foo_bar = 1, 2
+
+func main() { println(fooBar, foo_bar) }
@@ -0,0 +1,3 @@
+module example.com/redeclare
+
+go 1.24
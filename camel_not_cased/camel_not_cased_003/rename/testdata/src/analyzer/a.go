@@ -0,0 +1,24 @@
+package analyzer
+
+import "encoding/json"
+
+const maxRetryCount = 3 // want `const maxRetryCount should be named max_retry_count`
+
+type Config struct { // want `type Config should be named config \(not fixable: exported; use -module\)`
+	ListenAddr string // want `field ListenAddr should be named Listen_addr \(not fixable: exported; use -module\)`
+	MaxConns   int    `json:"max_conns"` // want `field MaxConns should be named Max_conns \(not fixable: exported; use -module\)`
+}
+
+var foo_bar = 1
+
+func GetFileSize(filePath string) int { // want `func GetFileSize should be named get_file_size \(not fixable: exported; use -module\)` `local filePath should be named file_path`
+	return len(filePath) + maxRetryCount
+}
+
+func shadow(fooBar int) int { // want `local fooBar should be named foo_bar \(not fixable: .*would shadow foo_bar.*\)`
+	return fooBar + foo_bar
+}
+
+func encode(c Config) ([]byte, error) {
+	return json.Marshal(c)
+}
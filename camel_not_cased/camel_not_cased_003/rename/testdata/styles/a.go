@@ -0,0 +1,16 @@
+package main
+
+import "fmt"
+
+const maxRetryCount = 3
+
+type httpServer struct{ listenAddr string }
+
+func GetFileSize(filePath string) int { return len(filePath) }
+
+func (s *httpServer) startNow() {}
+
+func main() {
+	localValue := GetFileSize("x")
+	fmt.Println(localValue, maxRetryCount)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"reflect"
+	"text/template"
+)
+
+type Property struct {
+	XMLName    xml.Name `xml:"property"`
+	PropName   string   `xml:"name"`
+	PropValue  string   `json:",omitempty"`
+	MaxDepth   int
+	MinA, MinB int
+	Limits     Limits
+}
+
+// Limits is marshalled as a field of Property.
+type Limits struct {
+	MaxCount int
+}
+
+// Settings is read with a json.Decoder.
+type Settings struct {
+	LogLevel string
+}
+
+// Flags is never marshalled, but nothing shows that, so its fields get
+// every tag key.
+type Flags struct {
+	DryRun bool
+}
+
+// Record is marshalled through an any, where its type cannot be seen.
+type Record struct {
+	ItemCount int
+}
+
+func Store(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func main() {
+	p := Property{PropName: "x", MaxDepth: 1}
+	b, _ := json.Marshal(p)
+	fmt.Println(string(b), reflect.ValueOf(p).FieldByName("MaxDepth"))
+	t := template.Must(template.New("t").Parse("{{.PropValue}}"))
+	t.Execute(os.Stdout, p)
+	var s Settings
+	json.NewDecoder(os.Stdin).Decode(&s)
+	f := Flags{DryRun: true}
+	fmt.Println(s, f)
+	Store(Record{ItemCount: 1})
+}
@@ -0,0 +1,7 @@
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(configDir(), callAdd(1), debugLevel)
+}
@@ -0,0 +1,17 @@
+//go:build cgo
+
+package main
+
+/*
+static int addTwo(int x) { return x + 2; }
+*/
+import "C"
+
+type cgoBox struct {
+	BoxValue C.int `json:"box"`
+}
+
+func callAdd(inputValue int) int {
+	box := cgoBox{BoxValue: C.int(inputValue)}
+	return int(C.addTwo(box.BoxValue))
+}
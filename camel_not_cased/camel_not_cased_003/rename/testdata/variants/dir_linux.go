@@ -0,0 +1,8 @@
+package main
+
+import "os"
+
+func configDir() string {
+	homeDir, _ := os.UserHomeDir()
+	return homeDir + "/.config"
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !windows
+
+package main
+
+func configDir() string { return defaultDir }
+
+const defaultDir = "."
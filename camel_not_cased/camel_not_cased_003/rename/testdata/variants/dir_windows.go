@@ -0,0 +1,8 @@
+package main
+
+import "os"
+
+func configDir() string {
+	appData := os.Getenv("APPDATA")
+	return appData
+}
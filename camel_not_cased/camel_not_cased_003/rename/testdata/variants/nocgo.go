@@ -0,0 +1,7 @@
+//go:build !cgo
+
+package main
+
+func callAdd(inputValue int) int {
+	return inputValue + 2
+}
@@ -0,0 +1,5 @@
+//go:build !debug
+
+package main
+
+const debugLevel = 0
@@ -0,0 +1,196 @@
+package rename
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// StringsDirective marks a string literal whose mentions of renamed
+// identifiers should be renamed too. It goes in a comment on the line of
+// the literal or on the line before:
+//
+//	//camelnotcased:strings
+//	t := template.Must(template.New("x").Parse("{{.UserName}}"))
+const StringsDirective = "camelnotcased:strings"
+
+// Substitution is a mention of a renamed identifier that was replaced in a
+// comment or string literal.
+type Substitution struct {
+	Pos      token.Position
+	In       string // "comment" or "string"
+	Old, New string
+}
+
+func (s Substitution) String() string {
+	return s.Pos.String() + ": " + s.In + ": " + s.Old + " -> " + s.New
+}
+
+// mentionRegex matches a word that may name an identifier, optionally
+// qualified by the word before it (pkg.Name, recv.Field).
+var mentionRegex = regexp.MustCompile(`(?:([\p{L}_][\p{L}\p{N}_]*)\.)?([\p{L}_][\p{L}\p{N}_]*)`)
+
+// textRenamer finds mentions of renamed objects in the comments and string
+// literals of one file. A word is only renamed if, resolved like an
+// identifier at the position of the comment or literal, it denotes a
+// renamed object; so a local variable renamed in one function does not
+// change the same word elsewhere.
+type textRenamer struct {
+	plan    *Plan
+	pkg     *packages.Package
+	tokFile *token.File
+	file    *ast.File
+	// members maps doc and line comments of fields and methods to the
+	// member they document, which is not in lexical scope.
+	members map[*ast.CommentGroup][]types.Object
+}
+
+func newTextRenamer(plan *Plan, pkg *packages.Package, tokFile *token.File, file *ast.File) *textRenamer {
+	r := &textRenamer{plan: plan, pkg: pkg, tokFile: tokFile, file: file, members: map[*ast.CommentGroup][]types.Object{}}
+	attach := func(obj types.Object, groups ...*ast.CommentGroup) {
+		for _, cg := range groups {
+			if cg != nil && obj != nil {
+				r.members[cg] = append(r.members[cg], obj)
+			}
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Recv != nil {
+				attach(pkg.TypesInfo.Defs[n.Name], n.Doc)
+			}
+		case *ast.Field:
+			for _, name := range n.Names {
+				attach(pkg.TypesInfo.Defs[name], n.Doc, n.Comment)
+			}
+		}
+		return true
+	})
+	return r
+}
+
+// commentEdits renames mentions in the comments of the file.
+func (r *textRenamer) commentEdits() ([]textEdit, []Substitution) {
+	var edits []textEdit
+	var subs []Substitution
+	for _, cg := range r.file.Comments {
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, "//"+StringsDirective) {
+				continue
+			}
+			e, s := r.mentions(c.Slash, r.tokFile.Offset(c.Slash), c.Text, "comment", r.members[cg], false)
+			edits = append(edits, e...)
+			subs = append(subs, s...)
+		}
+	}
+	return edits, subs
+}
+
+// stringEdits renames mentions in the string literals of the file that are
+// marked with StringsDirective. Quotes and escape sequences are left alone.
+func (r *textRenamer) stringEdits() ([]textEdit, []Substitution) {
+	marked := map[int]bool{} // lines with a directive
+	for _, cg := range r.file.Comments {
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, "//"+StringsDirective) {
+				marked[r.tokFile.Line(c.Slash)] = true
+			}
+		}
+	}
+	if len(marked) == 0 {
+		return nil, nil
+	}
+
+	var edits []textEdit
+	var subs []Substitution
+	ast.Inspect(r.file, func(n ast.Node) bool {
+		lit, ok := n.(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING || len(lit.Value) < 2 {
+			return true
+		}
+		if line := r.tokFile.Line(lit.Pos()); !marked[line] && !marked[line-1] {
+			return true
+		}
+		raw := lit.Value[0] == '`'
+		e, s := r.mentions(lit.Pos(), r.tokFile.Offset(lit.Pos())+1, lit.Value[1:len(lit.Value)-1], "string", nil, !raw)
+		edits = append(edits, e...)
+		subs = append(subs, s...)
+		return true
+	})
+	return edits, subs
+}
+
+// mentions returns an edit for every word in text, which starts at byte
+// offset base of the file and sits at pos in the package, that denotes a
+// renamed object: either one of members or an object in scope at pos.
+func (r *textRenamer) mentions(pos token.Pos, base int, text, in string, members []types.Object, escapes bool) ([]textEdit, []Substitution) {
+	scope := r.pkg.Types.Scope().Innermost(pos)
+	if scope == nil {
+		scope = r.pkg.Types.Scope()
+	}
+	var edits []textEdit
+	var subs []Substitution
+	for _, m := range mentionRegex.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[4], m[5]
+		// A word right after a backslash is part of an escape sequence.
+		if escapes && m[0] > 0 && text[m[0]-1] == '\\' {
+			continue
+		}
+		word := text[start:end]
+		qualifier := ""
+		if m[2] >= 0 {
+			qualifier = text[m[2]:m[3]]
+			// The qualifier is itself a word in scope.
+			if obj := r.resolve(scope, "", qualifier, members); obj != nil {
+				if newName, ok := r.plan.newName(obj); ok && newName != qualifier {
+					edits = append(edits, textEdit{start: base + m[2], end: base + m[3], text: newName})
+					subs = append(subs, r.substitution(base+m[2], in, qualifier, newName))
+				}
+			}
+		}
+		if obj := r.resolve(scope, qualifier, word, members); obj != nil {
+			if newName, ok := r.plan.newName(obj); ok && newName != word {
+				edits = append(edits, textEdit{start: base + start, end: base + end, text: newName})
+				subs = append(subs, r.substitution(base+start, in, word, newName))
+			}
+		}
+	}
+	return edits, subs
+}
+
+// resolve returns the object that word denotes in scope, or as a member of
+// the object qualifier denotes, or as one of members.
+func (r *textRenamer) resolve(scope *types.Scope, qualifier, word string, members []types.Object) types.Object {
+	if qualifier == "" {
+		for _, m := range members {
+			if m.Name() == word {
+				return m
+			}
+		}
+		_, obj := scope.LookupParent(word, token.NoPos)
+		return obj
+	}
+	_, q := scope.LookupParent(qualifier, token.NoPos)
+	var t types.Type
+	switch q := q.(type) {
+	case *types.PkgName:
+		return q.Imported().Scope().Lookup(word)
+	case *types.Var:
+		t = q.Type()
+	case *types.TypeName:
+		t = q.Type()
+	default:
+		return nil
+	}
+	obj, _, _ := types.LookupFieldOrMethod(t, true, r.pkg.Types, word)
+	return obj
+}
+
+func (r *textRenamer) substitution(offset int, in, oldName, newName string) Substitution {
+	return Substitution{Pos: r.plan.fset.Position(r.tokFile.Pos(offset)), In: in, Old: oldName, New: newName}
+}
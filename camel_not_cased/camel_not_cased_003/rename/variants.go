@@ -0,0 +1,364 @@
+package rename
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/build/constraint"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// platforms lists the GOOS/GOARCH pairs the go tool supports
+// (go tool dist list).
+var platforms = map[string][]string{
+	"aix":       {"ppc64"},
+	"android":   {"386", "amd64", "arm", "arm64"},
+	"darwin":    {"amd64", "arm64"},
+	"dragonfly": {"amd64"},
+	"freebsd":   {"386", "amd64", "arm", "arm64"},
+	"illumos":   {"amd64"},
+	"ios":       {"amd64", "arm64"},
+	"js":        {"wasm"},
+	"linux":     {"386", "amd64", "arm", "arm64", "loong64", "mips", "mips64", "mips64le", "mipsle", "ppc64", "ppc64le", "riscv64", "s390x"},
+	"netbsd":    {"386", "amd64", "arm", "arm64"},
+	"openbsd":   {"386", "amd64", "arm", "arm64", "ppc64", "riscv64"},
+	"plan9":     {"386", "amd64", "arm"},
+	"solaris":   {"amd64"},
+	"wasip1":    {"wasm"},
+	"windows":   {"386", "amd64", "arm64"},
+}
+
+// unixOS are the systems matched by the "unix" build tag.
+var unixOS = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true, "hurd": true,
+	"illumos": true, "ios": true, "linux": true, "netbsd": true, "openbsd": true, "solaris": true,
+}
+
+// osAliases are systems that also build the files of another system.
+var osAliases = map[string]string{"android": "linux", "illumos": "solaris", "ios": "darwin"}
+
+var knownArch = func() map[string]bool {
+	arches := map[string]bool{}
+	for _, list := range platforms {
+		for _, arch := range list {
+			arches[arch] = true
+		}
+	}
+	return arches
+}()
+
+// buildVariant is one build configuration to load packages under.
+type buildVariant struct {
+	goos, goarch string
+	tags         []string
+}
+
+func hostVariant() buildVariant {
+	return buildVariant{goos: runtime.GOOS, goarch: runtime.GOARCH}
+}
+
+func (v buildVariant) String() string {
+	s := v.goos + "/" + v.goarch
+	if len(v.tags) > 0 {
+		s += " -tags=" + strings.Join(v.tags, ",")
+	}
+	return s
+}
+
+func (v buildVariant) isHost() bool {
+	return v.goos == runtime.GOOS && v.goarch == runtime.GOARCH
+}
+
+// env returns the environment for the go command. Cgo is only available
+// for the host.
+func (v buildVariant) env() []string {
+	if v.isHost() {
+		return nil // inherit
+	}
+	return append(os.Environ(), "GOOS="+v.goos, "GOARCH="+v.goarch, "CGO_ENABLED=0")
+}
+
+func (v buildVariant) buildFlags() []string {
+	if len(v.tags) == 0 {
+		return nil
+	}
+	return []string{"-tags=" + strings.Join(v.tags, ",")}
+}
+
+// matchTag reports whether tag is satisfied in v, as go/build would.
+func (v buildVariant) matchTag(tag string) bool {
+	switch {
+	case tag == v.goos || tag == v.goarch || tag == osAliases[v.goos]:
+		return true
+	case tag == "unix":
+		return unixOS[v.goos]
+	case tag == "cgo":
+		return v.isHost()
+	case tag == "gc" || strings.HasPrefix(tag, "go1."):
+		return true
+	}
+	for _, t := range v.tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// includes reports whether v builds the file with constraints c.
+func (v buildVariant) includes(c fileConstraints) bool {
+	if c.goos != "" && c.goos != v.goos && c.goos != osAliases[v.goos] {
+		return false
+	}
+	if c.goarch != "" && c.goarch != v.goarch {
+		return false
+	}
+	return c.expr == nil || c.expr.Eval(v.matchTag)
+}
+
+// fileConstraints are the build constraints of one file: its //go:build
+// line (or // +build lines) and its _GOOS/_GOARCH file name suffix.
+type fileConstraints struct {
+	expr         constraint.Expr
+	goos, goarch string
+}
+
+// readConstraints reads the build constraints of the file.
+func readConstraints(filename string) (fileConstraints, error) {
+	c := fileConstraints{}
+	c.goos, c.goarch = nameConstraints(filepath.Base(filename))
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return c, err
+	}
+	var plusBuild []constraint.Expr
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, len(data)+1)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "/*") {
+			continue
+		}
+		if !strings.HasPrefix(line, "//") {
+			break // constraints must come before the package clause
+		}
+		switch {
+		case constraint.IsGoBuild(line):
+			expr, err := constraint.Parse(line)
+			if err != nil {
+				return c, fmt.Errorf("%s: %v", filename, err)
+			}
+			c.expr = expr
+		case constraint.IsPlusBuild(line):
+			expr, err := constraint.Parse(line)
+			if err != nil {
+				return c, fmt.Errorf("%s: %v", filename, err)
+			}
+			plusBuild = append(plusBuild, expr)
+		}
+	}
+	if c.expr == nil {
+		for _, expr := range plusBuild {
+			if c.expr == nil {
+				c.expr = expr
+			} else {
+				c.expr = &constraint.AndExpr{X: c.expr, Y: expr}
+			}
+		}
+	}
+	return c, nil
+}
+
+// nameConstraints returns the GOOS and GOARCH implied by a file name such
+// as x_linux.go, x_arm64.go or x_windows_amd64_test.go.
+func nameConstraints(name string) (goos, goarch string) {
+	name = strings.TrimSuffix(name, ".go")
+	name = strings.TrimSuffix(name, "_test")
+	i := strings.Index(name, "_")
+	if i < 0 {
+		return "", ""
+	}
+	parts := strings.Split(name[i:], "_")
+	n := len(parts)
+	if n >= 2 && isKnownOS(parts[n-2]) && knownArch[parts[n-1]] {
+		return parts[n-2], parts[n-1]
+	}
+	if isKnownOS(parts[n-1]) {
+		return parts[n-1], ""
+	}
+	if knownArch[parts[n-1]] {
+		return "", parts[n-1]
+	}
+	return "", ""
+}
+
+func isKnownOS(s string) bool {
+	_, ok := platforms[s]
+	return ok
+}
+
+// maxVariantTags bounds the custom tags tried together for one file.
+const maxVariantTags = 8
+
+// buildVariants returns the configurations under which every one of files
+// is built at least once, starting with the host's, and the files that no
+// supported configuration builds. Files constrained by the "ignore" tag
+// are never built and are not reported.
+func buildVariants(files []string) ([]buildVariant, []string, error) {
+	variants := []buildVariant{hostVariant()}
+	var uncovered []string
+	for _, file := range files {
+		c, err := readConstraints(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		covered := false
+		for _, v := range variants {
+			if v.includes(c) {
+				covered = true
+				break
+			}
+		}
+		if covered {
+			continue
+		}
+		if v, ok := findVariant(c); ok {
+			variants = append(variants, v)
+		} else if !mentionsTag(c.expr, "ignore") {
+			uncovered = append(uncovered, file)
+		}
+	}
+	return variants, uncovered, nil
+}
+
+// findVariant looks for a configuration that builds a file with
+// constraints c, preferring the host's system and architecture.
+func findVariant(c fileConstraints) (buildVariant, bool) {
+	host := hostVariant()
+	var systems, custom []string
+	arches := map[string]bool{}
+	if c.goos != "" {
+		systems = []string{c.goos}
+	} else {
+		systems = []string{host.goos}
+	}
+	for _, tag := range exprTags(c.expr) {
+		switch {
+		case isKnownOS(tag):
+			if c.goos == "" && tag != host.goos {
+				systems = append(systems, tag)
+			}
+		case knownArch[tag]:
+			arches[tag] = true
+		case tag == "unix", tag == "cgo", tag == "gc", tag == "gccgo", tag == "ignore", strings.HasPrefix(tag, "go1."):
+		default:
+			custom = append(custom, tag)
+		}
+	}
+	if len(custom) > maxVariantTags {
+		custom = custom[:maxVariantTags]
+	}
+	// A file for "none of these systems" needs yet another one.
+	if c.goos == "" {
+		for _, goos := range sortedKeys(platformSet()) {
+			systems = append(systems, goos)
+		}
+	}
+
+	for _, goos := range systems {
+		candidates := []string{host.goarch}
+		if c.goarch != "" {
+			candidates = []string{c.goarch}
+		}
+		for _, arch := range sortedKeys(arches) {
+			candidates = append(candidates, arch)
+		}
+		candidates = append(candidates, platforms[goos]...)
+		for _, goarch := range candidates {
+			if !supported(goos, goarch) {
+				continue
+			}
+			for mask := 0; mask < 1<<len(custom); mask++ {
+				v := buildVariant{goos: goos, goarch: goarch}
+				for i, tag := range custom {
+					if mask&(1<<i) != 0 {
+						v.tags = append(v.tags, tag)
+					}
+				}
+				if v.includes(c) {
+					return v, true
+				}
+			}
+		}
+	}
+	return buildVariant{}, false
+}
+
+func platformSet() map[string]bool {
+	set := map[string]bool{}
+	for goos := range platforms {
+		set[goos] = true
+	}
+	return set
+}
+
+func supported(goos, goarch string) bool {
+	for _, arch := range platforms[goos] {
+		if arch == goarch {
+			return true
+		}
+	}
+	return false
+}
+
+// exprTags returns the tags mentioned in expr, in order of appearance.
+func exprTags(expr constraint.Expr) []string {
+	var tags []string
+	seen := map[string]bool{}
+	var walk func(constraint.Expr)
+	walk = func(expr constraint.Expr) {
+		switch e := expr.(type) {
+		case *constraint.TagExpr:
+			if !seen[e.Tag] {
+				seen[e.Tag] = true
+				tags = append(tags, e.Tag)
+			}
+		case *constraint.NotExpr:
+			walk(e.X)
+		case *constraint.AndExpr:
+			walk(e.X)
+			walk(e.Y)
+		case *constraint.OrExpr:
+			walk(e.X)
+			walk(e.Y)
+		}
+	}
+	if expr != nil {
+		walk(expr)
+	}
+	return tags
+}
+
+// mentionsTag reports whether expr mentions tag.
+func mentionsTag(expr constraint.Expr, tag string) bool {
+	for _, t := range exprTags(expr) {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,21 @@
+package rename
+
+import "testing"
+
+func TestNameConstraints(t *testing.T) {
+	tests := []struct {
+		name, goos, goarch string
+	}{
+		{"dir_linux.go", "linux", ""},
+		{"dir_windows_amd64_test.go", "windows", "amd64"},
+		{"asm_arm64.go", "", "arm64"},
+		{"linux.go", "", ""},
+		{"add_to_path.go", "", ""},
+	}
+	for _, tt := range tests {
+		goos, goarch := nameConstraints(tt.name)
+		if goos != tt.goos || goarch != tt.goarch {
+			t.Errorf("nameConstraints(%q) = %q, %q; want %q, %q", tt.name, goos, goarch, tt.goos, tt.goarch)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Package launcher runs programs whose paths are too long for the usual
+// system calls: past MAX_PATH (260) on Windows and PATH_MAX (4096) on
+// Linux.
+package launcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// Result is what a launched program left behind.
+type Result struct {
+	Exit_code int
+	Stdout    []byte
+	Stderr    []byte
+	Timed_out bool // killed, with everything it started, at Timeout or the deadline
+}
+
+// Options are how Launch_with runs a program.
+type Options struct {
+	// Args are the arguments after the program name, passed as they are:
+	// no shell sees them on Linux, and on Windows they are quoted so that
+	// CommandLineToArgvW, which most programs parse with, splits them back
+	// into the same strings.
+	Args []string
+
+	// Env is the environment, as "key=value" strings. Nil inherits this
+	// process's environment.
+	Env []string
+
+	// Dir is the working directory, which may be as long as the program's
+	// path. Empty keeps this process's. A relative program path is still
+	// taken from this process's working directory, not Dir.
+	Dir string
+
+	// Timeout kills the program and every process it started once it has
+	// run this long. Zero waits for as long as it takes.
+	Timeout time.Duration
+}
+
+// wait_delay is how long to wait for the output once the program has exited
+// or been killed, in case something it left running still holds it open.
+const wait_delay = 5 * time.Second
+
+// Launch runs the program at path, which may be any length, with args,
+// waits for it to finish and returns its exit code and output. A program
+// that runs and fails is not an error: its exit code says so.
+//
+// On Linux the directories of path are opened one at a time, each
+// relative to the one before, and the program is run through
+// /proc/self/fd, so no system call sees the whole path. On Windows the path
+// is made absolute and given the \\?\ prefix, which lifts MAX_PATH.
+func Launch(path string, args ...string) (*Result, error) {
+	return Launch_with(context.Background(), path, Options{Args: args})
+}
+
+// Launch_with runs the program at path as Launch does, with the arguments,
+// environment, working directory and timeout in options.
+//
+// Parameters:
+//   - ctx: Kills the program and its process tree when done, as Timeout does.
+//   - path: The program to run, of any length.
+//   - options: How to run it; the zero Options runs it as Launch does.
+//
+// Returns:
+//   - *Result: The exit code and output. It is also returned, with what
+//     the program wrote before it was killed, when ctx or Timeout ends it.
+//   - error: When the program could not be started, or was killed, in which
+//     case it wraps ctx.Err() or context.DeadlineExceeded.
+//
+// Example:
+//
+//	result, err := launcher.Launch_with(ctx, longPath, launcher.Options{
+//	    Args:    []string{"--name", "two words", `C:\dir\`},
+//	    Env:     append(os.Environ(), "LOG_LEVEL=debug"),
+//	    Dir:     filepath.Dir(longPath),
+//	    Timeout: time.Minute,
+//	})
+//	if result != nil && result.Timed_out {
+//	    fmt.Println("gave up after a minute")
+//	}
+//
+// The process tree is killed as a whole: on Linux the program is started
+// in a process group of its own, and on Windows it is put in a job object.
+// A process that leaves its group, as daemons do, escapes on Linux.
+func Launch_with(ctx context.Context, path string, options Options) (*Result, error) {
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	exePath, cleanup, err := resolve_executable(path)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	cmd := exec.Command(exePath, options.Args...)
+	cmd.Args[0] = path
+	cmd.Env = options.Env
+	if options.Dir != "" {
+		dirPath, cleanupDir, err := resolve_dir(options.Dir)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanupDir()
+		cmd.Dir = dirPath
+		if cmd.Env == nil && runtime.GOOS != "windows" {
+			// exec would set PWD to the resolved directory; give the
+			// program the real one.
+			if absDir, err := filepath.Abs(options.Dir); err == nil {
+				cmd.Env = append(os.Environ(), "PWD="+absDir)
+			}
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = wait_delay
+
+	tree, err := start_tree(cmd)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.close()
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	var killed error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		killed = ctx.Err()
+		if killErr := tree.kill(); killErr != nil {
+			killed = fmt.Errorf("%w (and killing it failed: %v)", killed, killErr)
+		}
+		err = <-done
+	}
+
+	result := &Result{Stdout: stdout.Bytes(), Stderr: stderr.Bytes()}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.Exit_code = exitErr.ExitCode()
+		err = nil
+	}
+	if killed != nil {
+		result.Timed_out = errors.Is(killed, context.DeadlineExceeded)
+		return result, fmt.Errorf("%s was killed: %w", path, killed)
+	}
+	if err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+		return nil, err
+	}
+	return result, nil
+}
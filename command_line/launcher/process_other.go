@@ -0,0 +1,24 @@
+//go:build !unix && !windows
+
+package launcher
+
+import "os/exec"
+
+// process_tree is a started program. These systems have no process
+// groups or job objects here, so only the program itself can be killed.
+type process_tree struct {
+	cmd *exec.Cmd
+}
+
+func start_tree(cmd *exec.Cmd) (*process_tree, error) {
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &process_tree{cmd: cmd}, nil
+}
+
+func (t *process_tree) kill() error {
+	return t.cmd.Process.Kill()
+}
+
+func (t *process_tree) close() {}
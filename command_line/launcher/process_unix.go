@@ -0,0 +1,36 @@
+//go:build unix
+
+package launcher
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// process_tree is a started program and the processes it starts, kept
+// together in a process group of their own.
+type process_tree struct {
+	pgid int
+}
+
+// start_tree starts cmd as the leader of a new process group, which the
+// processes it starts join. Its arguments reach it as they are, with no
+// shell in between.
+func start_tree(cmd *exec.Cmd) (*process_tree, error) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &process_tree{pgid: cmd.Process.Pid}, nil
+}
+
+// kill kills every process in the group.
+func (t *process_tree) kill() error {
+	err := syscall.Kill(-t.pgid, syscall.SIGKILL)
+	if err == syscall.ESRCH {
+		return nil // all gone already
+	}
+	return err
+}
+
+func (t *process_tree) close() {}
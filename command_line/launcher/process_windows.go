@@ -0,0 +1,137 @@
+//go:build windows
+
+package launcher
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32                     = syscall.NewLazyDLL("kernel32.dll")
+	procCreateJobObjectW         = kernel32.NewProc("CreateJobObjectW")
+	procAssignProcessToJobObject = kernel32.NewProc("AssignProcessToJobObject")
+	procTerminateJobObject       = kernel32.NewProc("TerminateJobObject")
+	procThread32First            = kernel32.NewProc("Thread32First")
+	procThread32Next             = kernel32.NewProc("Thread32Next")
+	procOpenThread               = kernel32.NewProc("OpenThread")
+	procResumeThread             = kernel32.NewProc("ResumeThread")
+)
+
+const (
+	_PROCESS_TERMINATE     = 0x0001
+	_PROCESS_SET_QUOTA     = 0x0100
+	_THREAD_SUSPEND_RESUME = 0x0002
+	_CREATE_SUSPENDED      = 0x00000004
+)
+
+// thread_entry is THREADENTRY32.
+type thread_entry struct {
+	size           uint32
+	usage          uint32
+	thread_id      uint32
+	owner_id       uint32
+	base_priority  int32
+	delta_priority int32
+	flags          uint32
+}
+
+// process_tree is a started program and the processes it starts, kept
+// together in a job object: children join their parent's job, so
+// terminating the job kills them all.
+type process_tree struct {
+	cmd *exec.Cmd
+	job syscall.Handle
+}
+
+// start_tree starts cmd with its arguments quoted by command_line and puts
+// it in a new job object. The program is created suspended and only let
+// run once it is in the job, so nothing it starts escapes the job. If the
+// job cannot be made, killing the tree kills the program alone.
+func start_tree(cmd *exec.Cmd) (*process_tree, error) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: command_line(cmd.Args), CreationFlags: _CREATE_SUSPENDED}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	tree := &process_tree{cmd: cmd}
+	tree.job = join_job(uint32(cmd.Process.Pid))
+	if err := resume_process(uint32(cmd.Process.Pid)); err != nil {
+		tree.kill()
+		cmd.Wait()
+		tree.close()
+		return nil, fmt.Errorf("resume %s: %w", cmd.Args[0], err)
+	}
+	return tree, nil
+}
+
+// join_job puts the process in a new job object and returns the job, or 0
+// if it could not.
+func join_job(pid uint32) syscall.Handle {
+	job, _, _ := procCreateJobObjectW.Call(0, 0)
+	if job == 0 {
+		return 0
+	}
+	process, err := syscall.OpenProcess(_PROCESS_TERMINATE|_PROCESS_SET_QUOTA, false, pid)
+	if err != nil {
+		syscall.CloseHandle(syscall.Handle(job))
+		return 0
+	}
+	defer syscall.CloseHandle(process)
+	if ok, _, _ := procAssignProcessToJobObject.Call(job, uintptr(process)); ok == 0 {
+		syscall.CloseHandle(syscall.Handle(job))
+		return 0
+	}
+	return syscall.Handle(job)
+}
+
+// resume_process lets a process created suspended run, by resuming its
+// threads; os/exec keeps no handle to its main thread to resume instead.
+func resume_process(pid uint32) error {
+	snapshot, err := syscall.CreateToolhelp32Snapshot(syscall.TH32CS_SNAPTHREAD, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.CloseHandle(snapshot)
+
+	resumed := 0
+	entry := thread_entry{size: uint32(unsafe.Sizeof(thread_entry{}))}
+	ok, _, err := procThread32First.Call(uintptr(snapshot), uintptr(unsafe.Pointer(&entry)))
+	for ; ok != 0; ok, _, err = procThread32Next.Call(uintptr(snapshot), uintptr(unsafe.Pointer(&entry))) {
+		if entry.owner_id != pid {
+			continue
+		}
+		thread, _, openErr := procOpenThread.Call(_THREAD_SUSPEND_RESUME, 0, uintptr(entry.thread_id))
+		if thread == 0 {
+			return fmt.Errorf("OpenThread: %w", openErr)
+		}
+		count, _, resumeErr := procResumeThread.Call(thread)
+		syscall.CloseHandle(syscall.Handle(thread))
+		if int32(count) == -1 {
+			return fmt.Errorf("ResumeThread: %w", resumeErr)
+		}
+		resumed++
+	}
+	if resumed == 0 {
+		return fmt.Errorf("no threads found to resume: %v", err)
+	}
+	return nil
+}
+
+// kill terminates every process in the job.
+func (t *process_tree) kill() error {
+	if t.job == 0 {
+		return t.cmd.Process.Kill()
+	}
+	if ok, _, err := procTerminateJobObject.Call(uintptr(t.job), 1); ok == 0 {
+		return fmt.Errorf("TerminateJobObject: %w", err)
+	}
+	return nil
+}
+
+func (t *process_tree) close() {
+	if t.job != 0 {
+		syscall.CloseHandle(t.job)
+	}
+}
@@ -0,0 +1,55 @@
+package launcher
+
+import "strings"
+
+// command_line joins args into a Windows command line that
+// CommandLineToArgvW, and the C runtime, split back into the same
+// strings. The program name is parsed by simpler rules, with no escapes,
+// so it is only put in quotes; Windows does not allow quotes in paths.
+// Only Windows uses it, but it has no build tag, so it is tested on every
+// system.
+func command_line(args []string) string {
+	var builder strings.Builder
+	for i, arg := range args {
+		if i > 0 {
+			builder.WriteByte(' ')
+		}
+		if i == 0 {
+			if arg == "" || strings.ContainsAny(arg, " \t") {
+				builder.WriteString(`"` + arg + `"`)
+			} else {
+				builder.WriteString(arg)
+			}
+			continue
+		}
+		append_quoted(&builder, arg)
+	}
+	return builder.String()
+}
+
+// append_quoted writes arg as one argument. Backslashes are literal except
+// before a quote, so a run of them is doubled when a quote follows, whether
+// one from arg or the closing one.
+func append_quoted(builder *strings.Builder, arg string) {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\v\"") {
+		builder.WriteString(arg)
+		return
+	}
+	builder.WriteByte('"')
+	backslashes := 0
+	for i := 0; i < len(arg); i++ {
+		switch arg[i] {
+		case '\\':
+			backslashes++
+			continue
+		case '"':
+			builder.WriteString(strings.Repeat(`\`, 2*backslashes+1))
+		default:
+			builder.WriteString(strings.Repeat(`\`, backslashes))
+		}
+		builder.WriteByte(arg[i])
+		backslashes = 0
+	}
+	builder.WriteString(strings.Repeat(`\`, 2*backslashes))
+	builder.WriteByte('"')
+}
@@ -0,0 +1,106 @@
+package launcher
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// split_command_line splits a command line as CommandLineToArgvW does:
+// the program name up to the first space or tab, or between quotes, then
+// each argument with 2n backslashes before a quote standing for n and a
+// quote that opens or closes, and 2n+1 for n and a literal quote.
+func split_command_line(line string) []string {
+	var args []string
+	i := 0
+	if strings.HasPrefix(line, `"`) {
+		end := strings.IndexByte(line[1:], '"')
+		args = append(args, line[1:1+end])
+		i = end + 2
+	} else {
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			end = len(line)
+		}
+		args = append(args, line[:end])
+		i = end
+	}
+
+	for {
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		if i == len(line) {
+			return args
+		}
+		var arg strings.Builder
+		quoted := false
+		for ; i < len(line); i++ {
+			backslashes := 0
+			for i < len(line) && line[i] == '\\' {
+				backslashes++
+				i++
+			}
+			if i < len(line) && line[i] == '"' {
+				arg.WriteString(strings.Repeat(`\`, backslashes/2))
+				if backslashes%2 == 1 {
+					arg.WriteByte('"')
+				} else {
+					quoted = !quoted
+				}
+				continue
+			}
+			arg.WriteString(strings.Repeat(`\`, backslashes))
+			if i == len(line) || !quoted && (line[i] == ' ' || line[i] == '\t') {
+				break
+			}
+			arg.WriteByte(line[i])
+		}
+		args = append(args, arg.String())
+	}
+}
+
+func TestCommand_line(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{`C:\bin\tool.exe`}, `C:\bin\tool.exe`},
+		{[]string{`C:\Program Files\tool.exe`, "x"}, `"C:\Program Files\tool.exe" x`},
+		{[]string{`\\?\C:\long\tool.exe`, "plain"}, `\\?\C:\long\tool.exe plain`},
+		{[]string{"tool", ""}, `tool ""`},
+		{[]string{"tool", "two words"}, `tool "two words"`},
+		{[]string{"tool", "tab\there"}, "tool \"tab\there\""},
+		{[]string{"tool", "new\nline"}, "tool \"new\nline\""},
+		{[]string{"tool", `say "hi"`}, `tool "say \"hi\""`},
+		{[]string{"tool", `"`}, `tool "\""`},
+		{[]string{"tool", `C:\dir\`}, `tool C:\dir\`},
+		{[]string{"tool", `C:\my dir\`}, `tool "C:\my dir\\"`},
+		{[]string{"tool", `C:\my dir\\`}, `tool "C:\my dir\\\\"`},
+		{[]string{"tool", `a\"b`}, `tool "a\\\"b"`},
+		{[]string{"tool", `a\\"b`}, `tool "a\\\\\"b"`},
+		{[]string{"tool", `a\b c`}, `tool "a\b c"`},
+		{[]string{"tool", `\\server\share\`}, `tool \\server\share\`},
+	}
+	for _, tt := range tests {
+		if got := command_line(tt.args); got != tt.want {
+			t.Errorf("command_line(%q) = %s, want %s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestCommand_line_round_trip(t *testing.T) {
+	args := [][]string{
+		{`C:\Program Files\tool.exe`, "", "", "a"},
+		{"tool", `\`, `\\`, `\"`, `"\`, `" "`, `\ \`},
+		{"tool", "\t", " ", "  lead", "trail  ", "mid  dle"},
+		{"tool", `x\\\"y\\`, `--name="a b"`, `/p:C:\out dir\`},
+		{"tool", "ünïcødé", "日本語 テキスト", "😀"},
+	}
+	for _, want := range args {
+		line := command_line(want)
+		if got := split_command_line(line); !slices.Equal(got, want) {
+			t.Errorf("split_command_line(%s) = %q, want %q", line, got, want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+//go:build linux
+
+package launcher
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+// resolve_executable opens path one name at a time with openat, each
+// relative to the directory before, and returns /proc/self/fd/N for the
+// program. Every name is opened with O_PATH, which needs no read
+// permission, so programs and directories that may only be executed and
+// searched (mode 0711) work as they do with a plain exec. The child inherits the descriptor across fork, and the kernel
+// resolves the link before close-on-exec closes it, so this works for
+// programs of any path length. Scripts started with #! are the exception,
+// as their interpreter is handed a /proc/self/fd path that is gone by then.
+func resolve_executable(path string) (string, func(), error) {
+	dirFd, name, err := open_parent(path)
+	if err != nil {
+		return "", nil, err
+	}
+	exeFd, err := syscall.Openat(dirFd, name, _O_PATH|syscall.O_CLOEXEC, 0)
+	close_dir(dirFd)
+	if err != nil {
+		return "", nil, fmt.Errorf("open program %q: %w", name, err)
+	}
+	return fmt.Sprintf("/proc/self/fd/%d", exeFd), func() { syscall.Close(exeFd) }, nil
+}
+
+// resolve_dir opens the directory at path as resolve_executable opens a
+// program, and returns /proc/self/fd/N for it. The child changes to it
+// before exec, while the descriptor is still open.
+func resolve_dir(path string) (string, func(), error) {
+	dirFd, name, err := open_parent(strings.TrimRight(path, "/") + "/.")
+	if err != nil {
+		return "", nil, err
+	}
+	fd, err := syscall.Openat(dirFd, name, _O_PATH|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	close_dir(dirFd)
+	if err != nil {
+		return "", nil, fmt.Errorf("open working directory: %w", err)
+	}
+	return fmt.Sprintf("/proc/self/fd/%d", fd), func() { syscall.Close(fd) }, nil
+}
+
+// open_parent opens the directories of path and returns the last of them,
+// open, with the name in it that path ends with.
+func open_parent(path string) (int, string, error) {
+	dirFd := _AT_FDCWD
+	rest := path
+	if strings.HasPrefix(rest, "/") {
+		fd, err := syscall.Open("/", _O_PATH|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+		if err != nil {
+			return 0, "", fmt.Errorf("open /: %w", err)
+		}
+		dirFd = fd
+		rest = strings.TrimLeft(rest, "/")
+	}
+
+	names := strings.Split(rest, "/")
+	for i, name := range names[:len(names)-1] {
+		if name == "" {
+			continue // a doubled slash
+		}
+		fd, err := syscall.Openat(dirFd, name, _O_PATH|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+		close_dir(dirFd)
+		if err != nil {
+			return 0, "", fmt.Errorf("open directory %q (name %d of %d): %w", name, i+1, len(names), err)
+		}
+		dirFd = fd
+	}
+	return dirFd, names[len(names)-1], nil
+}
+
+func close_dir(dirFd int) {
+	if dirFd != _AT_FDCWD {
+		syscall.Close(dirFd)
+	}
+}
+
+const (
+	// _AT_FDCWD makes openat resolve a name relative to the working
+	// directory.
+	_AT_FDCWD = -100
+
+	// _O_PATH opens a file only to refer to it, not to read it. The
+	// syscall package defines it for some architectures only; the value
+	// is the same on all those Go supports.
+	_O_PATH = 0x200000
+)
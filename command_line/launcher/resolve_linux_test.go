@@ -0,0 +1,96 @@
+//go:build linux
+
+package launcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain runs as the launched program when the tests start a copy of
+// their own binary: it prints its working directory and exits with
+// program_exit_code.
+func TestMain(m *testing.M) {
+	if os.Getenv("LAUNCHER_TEST_PROGRAM") == "1" {
+		dir, err := os.Getwd()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Print(dir)
+		os.Exit(program_exit_code)
+	}
+	os.Exit(m.Run())
+}
+
+const program_exit_code = 7
+
+// TestLaunch_with_past_path_max copies the test binary, execute-only, to
+// the bottom of a tree deeper than PATH_MAX and runs it there with Dir the
+// same directory, which only works if neither path reaches a system call
+// whole.
+func TestLaunch_with_past_path_max(t *testing.T) {
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	top := t.TempDir()
+	root, err := os.OpenRoot(top)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deepPath := top
+	for i := 0; len(deepPath) <= 4096+255; i++ {
+		name := fmt.Sprintf("%03d_%s", i, strings.Repeat("d", 251))
+		if err := root.Mkdir(name, 0o755); err != nil {
+			root.Close()
+			t.Fatal(err)
+		}
+		next, err := root.OpenRoot(name)
+		root.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		root = next
+		deepPath = filepath.Join(deepPath, name)
+	}
+	defer root.Close()
+
+	input, err := os.Open(self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer input.Close()
+	output, err := root.OpenFile("program", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o111)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.Copy(output, input)
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := root.Stat("program"); err != nil || info.Mode().Perm() != 0o111 {
+		t.Fatalf("program: got %v, %v; want mode 0111", info, err)
+	}
+
+	t.Setenv("LAUNCHER_TEST_PROGRAM", "1")
+	programPath := filepath.Join(deepPath, "program")
+	result, err := Launch_with(context.Background(), programPath, Options{Dir: deepPath})
+	if err != nil {
+		t.Fatalf("Launch_with a %d-byte path: %v", len(programPath), err)
+	}
+	if result.Exit_code != program_exit_code {
+		t.Errorf("exit code: got %d, want %d; stderr:\n%s", result.Exit_code, program_exit_code, result.Stderr)
+	}
+	if got := string(result.Stdout); got != deepPath {
+		t.Errorf("working directory: got %q (%d bytes), want the %d-byte directory of the program", got, len(got), len(deepPath))
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !windows
+
+package launcher
+
+// resolve_executable returns path unchanged: these systems have no
+// /proc/self/fd to run a program through, so the path must fit the usual
+// limit.
+func resolve_executable(path string) (string, func(), error) {
+	return path, func() {}, nil
+}
+
+func resolve_dir(path string) (string, func(), error) {
+	return path, func() {}, nil
+}
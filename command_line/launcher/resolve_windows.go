@@ -0,0 +1,36 @@
+//go:build windows
+
+package launcher
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// resolve_executable returns path as an absolute path with the \\?\
+// prefix (\\?\UNC\ for a share), which tells Windows to skip its path
+// parsing and with it the MAX_PATH limit.
+func resolve_executable(path string) (string, func(), error) {
+	return long_path(path), func() {}, nil
+}
+
+// resolve_dir returns the working directory path as resolve_executable
+// returns a program's. Windows may still refuse a working directory past
+// MAX_PATH where long paths are not enabled.
+func resolve_dir(path string) (string, func(), error) {
+	return long_path(path), func() {}, nil
+}
+
+func long_path(path string) string {
+	if strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+	if strings.HasPrefix(absPath, `\\`) {
+		return `\\?\UNC\` + strings.TrimPrefix(absPath, `\\`)
+	}
+	return `\\?\` + absPath
+}
@@ -1,52 +1,80 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
-	"syscall"
-	"unsafe"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+
+	"command_line/launcher"
 )
 
+// defaultPath is the program run when none is given: the one
+// generate_long_file_path builds at the end of its tree on Windows.
+const defaultPath = `\\?\C:\long-file-paths\mSKuGP9gFX\ZfioqxCduF\EewaJDhlYK\8r4kgHrLFS\8EV918wFvX\x64D0YHG3G\k77o7QCq0x\DwEedEczG5\4gY9gRh8J0\aR9t5gANa8\8MgHxqVUZp\fnVWu6f1Th\IqfNeTCn6Y\0Qw1kIulol\ahehDwqU20\OtvSoKCOjL\1IkjZhqGLf\GRfIjrZjiZ\W0CINpwjlT\x2u1v5DVn2\9d7TFoMCcs\cgpwIxGtd1\OHTmuXve1s\hello_world.exe`
+
+// string_list is a flag that may be given more than once.
+type string_list []string
+
+func (l *string_list) String() string { return strings.Join(*l, ",") }
+
+func (l *string_list) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func main() {
-	longPath := `\\?\C:\long-file-paths\mSKuGP9gFX\ZfioqxCduF\EewaJDhlYK\8r4kgHrLFS\8EV918wFvX\x64D0YHG3G\k77o7QCq0x\DwEedEczG5\4gY9gRh8J0\aR9t5gANa8\8MgHxqVUZp\fnVWu6f1Th\IqfNeTCn6Y\0Qw1kIulol\ahehDwqU20\OtvSoKCOjL\1IkjZhqGLf\GRfIjrZjiZ\W0CINpwjlT\x2u1v5DVn2\9d7TFoMCcs\cgpwIxGtd1\OHTmuXve1s\hello_world.exe`
+	var env string_list
+	flag.Var(&env, "env", "set an environment variable, as key=value (repeatable)")
+	clearEnv := flag.Bool("clear-env", false, "start from an empty environment instead of this one")
+	dir := flag.String("dir", "", "working directory for the program, of any length")
+	timeout := flag.Duration("timeout", 0, "kill the program and everything it started after this long, e.g. 30s")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: command_line [flags] <program> [arguments...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		if runtime.GOOS != "windows" {
+			flag.Usage()
+			os.Exit(2)
+		}
+		args = []string{defaultPath}
+	}
+	longPath := args[0]
+
+	options := launcher.Options{Args: args[1:], Dir: *dir, Timeout: *timeout}
+	if *clearEnv || len(env) > 0 {
+		options.Env = []string{}
+		if !*clearEnv {
+			options.Env = os.Environ()
+		}
+		options.Env = append(options.Env, env...)
+	}
 
 	fmt.Println("== Path Information ==")
 	fmt.Printf("Path   : %s\n", longPath)
-	fmt.Printf("Length : %d characters\n\n", len(longPath))
-
-	// Convert path and command line to UTF-16
-	appNameUTF16, _ := syscall.UTF16PtrFromString(longPath)
-	cmdLineUTF16, _ := syscall.UTF16PtrFromString(`"` + longPath + `"`)
-
-	// Setup process startup info
-	var si syscall.StartupInfo
-	var pi syscall.ProcessInformation
-	si.Cb = uint32(unsafe.Sizeof(si))
-
-	// Call CreateProcessW
-	err := syscall.CreateProcess(
-		appNameUTF16,     // lpApplicationName
-		cmdLineUTF16,     // lpCommandLine (quoted)
-		nil,              // lpProcessAttributes
-		nil,              // lpThreadAttributes
-		false,            // bInheritHandles
-		0,                // dwCreationFlags
-		nil,              // lpEnvironment
-		nil,              // lpCurrentDirectory
-		&si,              // lpStartupInfo
-		&pi,              // lpProcessInformation
-	)
+	fmt.Printf("Length : %d characters\n\n", utf8.RuneCountInString(longPath))
+
+	result, err := launcher.Launch_with(context.Background(), longPath, options)
+	if result != nil {
+		os.Stdout.Write(result.Stdout)
+		os.Stderr.Write(result.Stderr)
+	}
+	if result != nil && result.Timed_out {
+		fmt.Fprintf(os.Stderr, "Timed out after %v: the program and everything it started were killed.\n", *timeout)
+		os.Exit(1)
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "CreateProcessW failed: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Launch failed: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Wait for the process to finish
-	_, _ = syscall.WaitForSingleObject(pi.Process, syscall.INFINITE)
-
-	// Close handles
-	_ = syscall.CloseHandle(pi.Process)
-	_ = syscall.CloseHandle(pi.Thread)
-
-	fmt.Println("Execution completed.")
-}
\ No newline at end of file
+	fmt.Printf("Execution completed with exit code %d.\n", result.Exit_code)
+	os.Exit(result.Exit_code)
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tree_marker is the file create_deep_path leaves in the top directory of
+// every tree, so clean can tell the generator's trees from anything else
+// in the base path.
+const tree_marker = ".generate_long_file_path"
+
+func mark_tree(top *os.Root) error {
+	file, err := top.Create(tree_marker)
+	if err != nil {
+		return fmt.Errorf("mark the tree for clean: %w", err)
+	}
+	if _, err := file.WriteString("made by generate_long_file_path; generate_long_file_path clean removes this tree\n"); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// is_marked reports whether the directory top, in base_root, is the top of
+// a tree the generator made.
+func is_marked(base_root *os.Root, top string) bool {
+	info, err := base_root.Lstat(filepath.Join(top, tree_marker))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// clean_stats counts what clean removed, or would remove in a dry run.
+type clean_stats struct {
+	files, dirs int
+	dry_run     bool
+}
+
+// A clean_frame is a directory remove_tree is emptying: its path relative
+// to the base path, and how many of its entries were left in place, which
+// only a dry run does.
+type clean_frame struct {
+	path   string
+	kept   int
+	marker bool     // the tree_marker is here, to remove last
+	root   *os.Root // kept open every checkpoint_depth levels, or nil
+}
+
+// checkpoint_depth is how many levels apart remove_tree keeps directories
+// open to climb back up from.
+const checkpoint_depth = 64
+
+// remove_tree removes rel, relative to base_root, and everything below it,
+// deepest first. Every step goes through os.Root, as with openat and
+// unlinkat, so no system call sees more than one name however deep the
+// tree goes, and symbolic links are removed, never followed. Paths are
+// printed below base_path.
+//
+// It works down the tree without recursion and closes each directory
+// before going into the next, except one every checkpoint_depth levels, so
+// depth cannot run it out of file descriptors. On the way back up each
+// directory is reopened from the nearest of those.
+func remove_tree(base_root *os.Root, rel string, base_path string, stats *clean_stats) error {
+	info, err := base_root.Lstat(rel)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return remove_entry(base_root, rel, filepath.Join(base_path, rel), stats, false)
+	}
+
+	top_root, err := base_root.OpenRoot(rel)
+	if err != nil {
+		return err
+	}
+	stack := []clean_frame{{path: rel, root: top_root}}
+	current, err := top_root.OpenRoot(".")
+	if err != nil {
+		top_root.Close()
+		return err
+	}
+	defer func() {
+		current.Close()
+		for _, frame := range stack {
+			if frame.root != nil {
+				frame.root.Close()
+			}
+		}
+	}()
+	for {
+		top := &stack[len(stack)-1]
+		subdir, err := empty_dir(current, top, base_path, stats)
+		if err != nil {
+			return err
+		}
+		if subdir != "" {
+			next, err := current.OpenRoot(subdir)
+			if err != nil {
+				return err
+			}
+			current.Close()
+			current = next
+			frame := clean_frame{path: filepath.Join(top.path, subdir)}
+			if len(stack)%checkpoint_depth == 0 {
+				if frame.root, err = current.OpenRoot("."); err != nil {
+					return err
+				}
+			}
+			stack = append(stack, frame)
+			continue
+		}
+
+		// Empty: go back up and remove it from the directory above
+		if top.root != nil {
+			top.root.Close()
+			top.root = nil
+		}
+		stack = stack[:len(stack)-1]
+		if len(stack) == 0 {
+			return remove_entry(base_root, rel, filepath.Join(base_path, rel), stats, true)
+		}
+		parent, err := reopen(stack)
+		if err != nil {
+			return err
+		}
+		current.Close()
+		current = parent
+		if err := remove_entry(current, filepath.Base(top.path), filepath.Join(base_path, top.path), stats, true); err != nil {
+			return err
+		}
+		if stats.dry_run {
+			stack[len(stack)-1].kept++
+		}
+	}
+}
+
+// reopen opens the last directory of stack from the nearest one above it
+// that is still open.
+func reopen(stack []clean_frame) (*os.Root, error) {
+	last := stack[len(stack)-1]
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].root == nil {
+			continue
+		}
+		rel, err := filepath.Rel(stack[i].path, last.path)
+		if err != nil {
+			return nil, err
+		}
+		return stack[i].root.OpenRoot(rel)
+	}
+	return nil, fmt.Errorf("no open directory above %s", last.path)
+}
+
+// empty_dir removes the files in dir, the directory of frame, and returns
+// the name of the first subdirectory it meets, or "" when none is left.
+// Entries are read in batches, so a huge directory does not need its whole
+// listing in memory, and those a dry run listed already are skipped. The
+// tree_marker goes last, so a clean that fails part way can be run again.
+func empty_dir(dir *os.Root, frame *clean_frame, base_path string, stats *clean_stats) (string, error) {
+	listing, err := dir.Open(".")
+	if err != nil {
+		return "", err
+	}
+	defer listing.Close()
+
+	skip := frame.kept
+	for {
+		entries, err := listing.ReadDir(256)
+		for _, entry := range entries {
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if entry.Name() == tree_marker && !strings.ContainsRune(frame.path, filepath.Separator) {
+				frame.marker = true
+				if stats.dry_run {
+					frame.kept++
+				}
+				continue
+			}
+			if entry.IsDir() {
+				return entry.Name(), nil
+			}
+			display := filepath.Join(base_path, frame.path, entry.Name())
+			if err := remove_entry(dir, entry.Name(), display, stats, false); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return "", err
+			}
+			if stats.dry_run {
+				frame.kept++
+			}
+		}
+		if err == io.EOF {
+			if frame.marker {
+				return "", remove_entry(dir, tree_marker, filepath.Join(base_path, frame.path, tree_marker), stats, false)
+			}
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// remove_entry removes name, in parent, once it is empty or not a
+// directory, or in a dry run prints that it would.
+func remove_entry(parent *os.Root, name string, display string, stats *clean_stats, is_dir bool) error {
+	if is_dir {
+		stats.dirs++
+	} else {
+		stats.files++
+	}
+	if stats.dry_run {
+		fmt.Printf("would remove %s\n", display)
+		return nil
+	}
+	return parent.Remove(name)
+}
+
+// inside_base returns target as a path relative to base_path, or an error
+// if it is not strictly inside it.
+func inside_base(base_path, target string) (string, error) {
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(base_path, target)
+	}
+	rel, err := filepath.Rel(base_path, target)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", fmt.Errorf("%s is not inside the base path %s", target, base_path)
+	}
+	return rel, nil
+}
+
+// run_clean is the clean subcommand: it removes trees made by the
+// generator inside the base path, every such tree there when none is
+// named. A tree is known by the marker create_deep_path leaves in its top
+// directory, and anything else is left alone, named or not. Paths outside
+// the base path are refused, and os.Root keeps symbolic links inside the
+// tree from leading out of it.
+func run_clean(args []string) {
+	flags := flag.NewFlagSet("clean", flag.ExitOnError)
+	base_path := flags.String("base", default_base_path(), "directory the trees were created in; nothing outside it is touched")
+	dry_run := flags.Bool("dry-run", false, "list what would be removed without removing it")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: generate_long_file_path clean [-base dir] [-dry-run] [tree ...]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	absolute_base, err := filepath.Abs(*base_path)
+	if err != nil {
+		log.Fatalf("Failed to resolve base path: %v", err)
+	}
+	if filepath.Dir(absolute_base) == absolute_base {
+		log.Fatalf("Refusing to clean %s: the base path is the root of a file system", absolute_base)
+	}
+	base_root, err := os.OpenRoot(absolute_base)
+	if err != nil {
+		log.Fatalf("Failed to open base path: %v", err)
+	}
+	defer base_root.Close()
+
+	targets := flags.Args()
+	if len(targets) == 0 {
+		entries, err := os.ReadDir(absolute_base)
+		if err != nil {
+			log.Fatalf("Failed to read base path: %v", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() && is_marked(base_root, entry.Name()) {
+				targets = append(targets, entry.Name())
+			}
+		}
+	}
+
+	stats := &clean_stats{dry_run: *dry_run}
+	failed := false
+	for _, target := range targets {
+		rel, err := inside_base(absolute_base, target)
+		if err != nil {
+			log.Printf("Refusing to clean: %v", err)
+			failed = true
+			continue
+		}
+		top := strings.SplitN(rel, string(filepath.Separator), 2)[0]
+		if !is_marked(base_root, top) {
+			if _, err := base_root.Lstat(rel); errors.Is(err, fs.ErrNotExist) {
+				log.Printf("Nothing to clean at %s", filepath.Join(absolute_base, rel))
+				continue
+			}
+			log.Printf("Refusing to clean %s: %s has no %s marker, so the generator did not make it",
+				filepath.Join(absolute_base, rel), filepath.Join(absolute_base, top), tree_marker)
+			failed = true
+			continue
+		}
+		err = remove_tree(base_root, rel, absolute_base, stats)
+		if errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Nothing to clean at %s", filepath.Join(absolute_base, rel))
+		} else if err != nil {
+			log.Printf("Failed to clean %s: %v", rel, err)
+			failed = true
+		}
+	}
+
+	verb := "Removed"
+	if *dry_run {
+		verb = "Would remove"
+	}
+	fmt.Printf("%s %d files and %d directories from %s\n", verb, stats.files, stats.dirs, absolute_base)
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -1,43 +1,224 @@
 package main
 
 import (
-	"crypto/rand"
+	crypto_rand "crypto/rand"
+	"encoding/binary"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
-	"math/big"
+	"math/rand/v2"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"unicode/utf16"
 )
 
 const (
-	base_path          = `C:\long-file-paths`
-	alphanumeric_chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	random_name_length = 10
-	max_path_length    = 260
-	module_name        = "example.com/deep/project"
-	exe_name           = "hello_world.exe"
+	module_name = "example.com/deep/project"
+	exe_name    = "hello_world.exe"
 )
 
-func generate_random_string(length int) string {
+// Character sets for the names of the generated directories.
+var charsets = map[string]string{
+	"alphanumeric": "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
+	"unicode":      "äöüßéèñçøåłžёжщюяαβγδλπΩ日本語漢字中文한국어😀🚀",
+	"spaces":       "abcdefghijklmnopqrstuvwxyz0123456789     ",
+	"mixed":        "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 äöüßéñøжπ日本語😀-_.()[]{}'&+,;=@",
+}
+
+// A target is a limit to exceed. Lengths are counted the way the system
+// counts them: UTF-16 code units on Windows, bytes elsewhere.
+type path_target struct {
+	description      string
+	total_length     int // the path is made longer than this
+	component_length int // the length of each name
+}
+
+var targets = map[string]path_target{
+	"max_path": {"Windows MAX_PATH", 260, 10},
+	"path_max": {"Linux PATH_MAX", 4096, 64},
+	"name_max": {"Linux NAME_MAX (every name as long as allowed)", 4096, name_max},
+}
+
+// name_max is the longest name Linux file systems (and NTFS) accept.
+const name_max = 255
+
+func default_target() string {
+	if runtime.GOOS == "windows" {
+		return "max_path"
+	}
+	return "path_max"
+}
+
+func default_base_path() string {
+	if runtime.GOOS == "windows" {
+		return `C:\long-file-paths`
+	}
+	return filepath.Join(os.TempDir(), "long-file-paths")
+}
+
+// path_length returns the length of s as the system counts it.
+func path_length(s string) int {
+	if runtime.GOOS == "windows" {
+		return len(utf16.Encode([]rune(s)))
+	}
+	return len(s)
+}
+
+// new_random returns the generator for names. PCG is fully specified, so a
+// seed gives the same names with any Go release on any machine.
+func new_random(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, 0x9e3779b97f4a7c15))
+}
+
+// random_seed picks a seed for a run without -seed, to print so the run can
+// be repeated.
+func random_seed() uint64 {
+	var seed [8]byte
+	if _, err := crypto_rand.Read(seed[:]); err != nil {
+		log.Fatalf("Failed to pick a seed: %v", err)
+	}
+	return binary.LittleEndian.Uint64(seed[:])
+}
+
+// generate_random_string returns a name of exactly length units (as
+// path_length counts them) drawn from chars. Spaces and dots are kept away
+// from the ends, where Windows drops them and shells trip over them. A
+// drawn character is kept only if the rest of the name can still be filled
+// and ended, so with a character set like "é " every name comes out whole
+// or not at all.
+func generate_random_string(length int, chars []rune, random *rand.Rand) (string, error) {
+	sizes := make([]int, len(chars))
+	for i, char := range chars {
+		sizes[i] = path_length(string(char))
+	}
+	// can_fill[n] is whether n units can be filled with chars, and can_end[n]
+	// whether they can be filled with chars ending in one that may end a name.
+	can_fill := make([]bool, max(length, 0)+1)
+	can_end := make([]bool, max(length, 0)+1)
+	can_fill[0] = true
+	for n := 1; n <= length; n++ {
+		for i, size := range sizes {
+			if size > n {
+				continue
+			}
+			can_fill[n] = can_fill[n] || can_fill[n-size]
+			can_end[n] = can_end[n] || is_edge_char(chars[i]) && can_fill[n-size]
+		}
+	}
+	fits := func(char rune, size, used int) bool {
+		rest := length - used - size
+		if rest < 0 || (used == 0 || rest == 0) && !is_edge_char(char) {
+			return false
+		}
+		return rest == 0 || can_end[rest]
+	}
+	possible := false
+	for i, char := range chars {
+		possible = possible || fits(char, sizes[i], 0)
+	}
+	if !possible {
+		return "", fmt.Errorf("characters %q cannot make a name of %d units: a name must start and end with a character other than a space or a dot", string(chars), length)
+	}
+
 	var builder strings.Builder
-	for i := 0; i < length; i++ {
-		random_index, _ := rand.Int(rand.Reader, big.NewInt(int64(len(alphanumeric_chars))))
-		builder.WriteByte(alphanumeric_chars[random_index.Int64()])
+	used := 0
+	for used < length {
+		i := random.IntN(len(chars))
+		if !fits(chars[i], sizes[i], used) {
+			continue
+		}
+		builder.WriteRune(chars[i])
+		used += sizes[i]
 	}
-	return builder.String()
+	return builder.String(), nil
 }
 
-func copy_file(src, dst string) error {
+// is_edge_char reports whether char may start or end a name.
+func is_edge_char(char rune) bool {
+	return char != ' ' && char != '.'
+}
+
+// generate_components returns the names to add below base_path so the full
+// path is longer than total_length.
+func generate_components(base_path string, total_length, component_length int, chars []rune, random *rand.Rand) ([]string, error) {
+	var components []string
+	current_length := path_length(base_path)
+	separator := path_length(string(filepath.Separator))
+	for current_length <= total_length {
+		name, err := generate_random_string(component_length, chars, random)
+		if err != nil {
+			return nil, err
+		}
+		components = append(components, name)
+		current_length += separator + path_length(name)
+	}
+	return components, nil
+}
+
+// create_deep_path creates the chain of directories one level at a time,
+// each relative to the one above, so no system call ever sees a path
+// longer than a single name. The top directory is marked as made by the
+// generator, for clean. It returns the deepest directory, open.
+func create_deep_path(base_path string, components []string) (*os.Root, error) {
+	if err := os.MkdirAll(base_path, os.ModePerm); err != nil {
+		return nil, err
+	}
+	root, err := os.OpenRoot(base_path)
+	if err != nil {
+		return nil, err
+	}
+	for depth, name := range components {
+		if err := root.Mkdir(name, os.ModePerm); err != nil && !errors.Is(err, os.ErrExist) {
+			root.Close()
+			return nil, fmt.Errorf("mkdir %q in %s: %w", name, root.Name(), err)
+		}
+		next, err := root.OpenRoot(name)
+		root.Close()
+		if err != nil {
+			return nil, err
+		}
+		root = next
+		if depth == 0 {
+			if err := mark_tree(root); err != nil {
+				root.Close()
+				return nil, err
+			}
+		}
+	}
+	return root, nil
+}
+
+// probe_name_max tries to create a name one unit longer than name_max in
+// root, which the system should refuse.
+func probe_name_max(root *os.Root) {
+	name := strings.Repeat("n", name_max+1)
+	err := root.Mkdir(name, os.ModePerm)
+	if err == nil {
+		root.Remove(name)
+		fmt.Printf("A name of %d units was accepted: this file system allows more than NAME_MAX\n", name_max+1)
+		return
+	}
+	fmt.Printf("A name of %d units was refused as expected: %v\n", name_max+1, errors.Unwrap(err))
+}
+
+func copy_file(src string, dst_root *os.Root, dst string) error {
 	input, err := os.Open(src)
 	if err != nil {
 		return err
 	}
 	defer input.Close()
+	info, err := input.Stat()
+	if err != nil {
+		return err
+	}
 
-	output, err := os.Create(dst)
+	// Keep the mode, so the built program stays executable on Linux
+	output, err := dst_root.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
 	if err != nil {
 		return err
 	}
@@ -48,18 +229,93 @@ func copy_file(src, dst string) error {
 }
 
 func main() {
-	current_path := base_path
+	if len(os.Args) > 1 && os.Args[1] == "clean" {
+		run_clean(os.Args[2:])
+		return
+	}
 
-	for len(current_path) <= max_path_length {
-		random_folder_name := generate_random_string(random_name_length)
-		current_path = filepath.Join(current_path, random_folder_name)
+	target_name := flag.String("target", default_target(), "limit to exceed: max_path (260), path_max (4096) or name_max (255-byte names)")
+	base_path := flag.String("base", default_base_path(), "directory to create the tree in")
+	total_length := flag.Int("length", 0, "make the path longer than this (default from -target)")
+	component_length := flag.Int("component", 0, "length of each directory name (default from -target)")
+	charset_name := flag.String("charset", "alphanumeric", "characters for names: alphanumeric, unicode, spaces or mixed")
+	chars := flag.String("chars", "", "characters for names, instead of -charset")
+	verify := flag.Bool("verify", false, "then try file operations and go build at each length threshold and print a pass/fail matrix")
+	seed := flag.Uint64("seed", 0, "seed for the names, to make the same tree again (default a random seed, printed)")
+	manifest_path := flag.String("manifest", "", "write a JSON manifest of the directories and files created to this file")
+	replay_path := flag.String("replay", "", "make the tree described by this manifest again, name for name")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: generate_long_file_path [flags]\n       generate_long_file_path clean [-base dir] [-dry-run] [tree ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	set_flags := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set_flags[f.Name] = true })
+
+	target, ok := targets[*target_name]
+	if !ok {
+		log.Fatalf("Unknown target %q: want max_path, path_max or name_max", *target_name)
+	}
+	if *total_length > 0 {
+		target.total_length = *total_length
+	}
+	if *component_length > 0 {
+		target.component_length = *component_length
+	}
+	charset := *chars
+	if charset == "" {
+		if charset, ok = charsets[*charset_name]; !ok {
+			log.Fatalf("Unknown charset %q: want alphanumeric, unicode, spaces or mixed", *charset_name)
+		}
+	}
+	if strings.ContainsAny(charset, `/\:*?"<>|`+"\x00") {
+		log.Fatalf("Characters %q include a path separator or a character Windows forbids in names", charset)
 	}
 
+	if !set_flags["seed"] {
+		*seed = random_seed()
+	}
+	var components []string
+	if *replay_path != "" {
+		replayed, err := read_manifest(*replay_path)
+		if err != nil {
+			log.Fatalf("Failed to read manifest: %v", err)
+		}
+		if !set_flags["base"] {
+			*base_path = replayed.Base_path
+		}
+		if replayed.Goos != runtime.GOOS {
+			fmt.Printf("Replaying a tree made on %s; lengths are counted differently here\n", replayed.Goos)
+		}
+		components = replayed.components()
+		*seed, *target_name, charset = replayed.Seed, replayed.Target, replayed.Chars
+		target.total_length, target.component_length = replayed.Total_length, replayed.Component_length
+		if t, ok := targets[replayed.Target]; ok {
+			target.description = t.description
+		}
+	} else {
+		generated, err := generate_components(*base_path, target.total_length, target.component_length, []rune(charset), new_random(*seed))
+		if err != nil {
+			log.Fatalf("Failed to generate names: %v (check -chars, -charset and -component)", err)
+		}
+		components = generated
+		fmt.Printf("Seed: %d (add -seed %d to make this tree again)\n", *seed, *seed)
+	}
+	record := new_manifest(*seed, *target_name, *base_path, target, charset)
+	record.add_dirs(components)
+	current_path := filepath.Join(append([]string{*base_path}, components...)...)
 	fmt.Printf("Creating directory path: %s\n", current_path)
-	err := os.MkdirAll(current_path, os.ModePerm)
+	fmt.Printf("Path length: %d, past %s (%d), in %d names of %d\n",
+		path_length(current_path), target.description, target.total_length, len(components), target.component_length)
+
+	deep_root, err := create_deep_path(*base_path, components)
 	if err != nil {
 		log.Fatalf("Failed to create directories: %v", err)
 	}
+	defer deep_root.Close()
+	if *target_name == "name_max" {
+		probe_name_max(deep_root)
+	}
 
 	// Step 1: Create Go project in a temp short path
 	temp_path, err := os.MkdirTemp("", "short_goproject")
@@ -102,16 +358,28 @@ func main() {
 		log.Fatalf("Failed to run 'go build': %v", err)
 	}
 
-	// Copy files to long path
+	// Copy files to long path, relative to the deepest directory
 	files := []string{"go.mod", "main.go", exe_name}
 	for _, file := range files {
 		src := filepath.Join(temp_path, file)
-		dst := filepath.Join(current_path, file)
-		err = copy_file(src, dst)
+		err = copy_file(src, deep_root, file)
 		if err != nil {
 			log.Fatalf("Failed to copy %s to long path: %v", file, err)
 		}
+		if info, err := deep_root.Stat(file); err == nil {
+			record.add_file(components, file, info.Size())
+		}
+	}
+	if *manifest_path != "" {
+		if err := write_manifest(*manifest_path, record); err != nil {
+			log.Fatalf("Failed to write manifest: %v", err)
+		}
+		fmt.Printf("Manifest of %d entries written to %s\n", len(record.Entries), *manifest_path)
 	}
 
 	fmt.Printf("Go project built and copied to:\n%s\n", current_path)
+
+	if *verify && !run_verify(*base_path, components, temp_path) {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestGenerate_random_string(t *testing.T) {
+	tests := []struct {
+		name   string
+		length int
+		chars  string
+		ok     bool
+		bytes  bool // lengths are in bytes, so the case only holds off Windows
+	}{
+		{"alphanumeric", 10, charsets["alphanumeric"], true, false},
+		{"unicode", 64, charsets["unicode"], true, false},
+		{"unicode at name_max", name_max, charsets["unicode"], true, false},
+		{"spaces", 10, charsets["spaces"], true, false},
+		{"mixed", 255, charsets["mixed"], true, false},
+		{"one unit", 1, "a.", true, false},
+		{"only two-byte names", 4, "é ", true, true},
+		{"space inside", 5, "é ", true, true},
+		{"odd length from two-byte ends", 3, "é ", false, true},
+		{"shorter than any end", 1, "é ", false, true},
+		{"four-byte characters", 8, "😀", true, true},
+		{"between four-byte characters", 6, "😀", false, true},
+		{"only spaces and dots", 10, " .", false, false},
+		{"no characters", 10, "", false, false},
+		{"zero length", 0, "a", false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.bytes && runtime.GOOS == "windows" {
+				t.Skip("lengths are counted in UTF-16 units on Windows")
+			}
+			for seed := range uint64(50) {
+				name, err := generate_random_string(test.length, []rune(test.chars), new_random(seed))
+				if !test.ok {
+					if err == nil {
+						t.Fatalf("seed %d: got %q, want an error", seed, name)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("seed %d: %v", seed, err)
+				}
+				if got := path_length(name); got != test.length {
+					t.Errorf("seed %d: %q is %d units, want %d", seed, name, got, test.length)
+				}
+				first, _ := utf8.DecodeRuneInString(name)
+				last, _ := utf8.DecodeLastRuneInString(name)
+				if !is_edge_char(first) || !is_edge_char(last) {
+					t.Errorf("seed %d: %q starts or ends with a space or a dot", seed, name)
+				}
+				for _, char := range name {
+					if !strings.ContainsRune(test.chars, char) {
+						t.Errorf("seed %d: %q has %q, not in %q", seed, name, char, test.chars)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestGenerate_components_limits checks for every target and character set
+// that each name has the target's length and fits in NAME_MAX bytes, and
+// that the path is past the target's total length by no more than one name.
+func TestGenerate_components_limits(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "long-file-paths")
+	for target_name, target := range targets {
+		for charset_name, charset := range charsets {
+			t.Run(target_name+"/"+charset_name, func(t *testing.T) {
+				components, err := generate_components(base, target.total_length, target.component_length, []rune(charset), new_random(1))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, name := range components {
+					if got := path_length(name); got != target.component_length {
+						t.Errorf("%q is %d units, want %d", name, got, target.component_length)
+					}
+					if runtime.GOOS != "windows" && len(name) > name_max {
+						t.Errorf("%q is %d bytes, past NAME_MAX (%d)", name, len(name), name_max)
+					}
+				}
+				full := filepath.Join(append([]string{base}, components...)...)
+				if got := path_length(full); got <= target.total_length {
+					t.Errorf("path is %d units, want more than %d", got, target.total_length)
+				}
+				short := filepath.Join(append([]string{base}, components[:len(components)-1]...)...)
+				if got := path_length(short); got > target.total_length {
+					t.Errorf("path without its last name is %d units, already past %d", got, target.total_length)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// A manifest records a generated tree: the settings it was made with and
+// every directory and file in it, so it can be made again elsewhere,
+// either from the same settings and seed or name for name with -replay.
+type manifest struct {
+	Seed             uint64           `json:"seed"`
+	Target           string           `json:"target"`
+	Base_path        string           `json:"base_path"`
+	Total_length     int              `json:"total_length"`
+	Component_length int              `json:"component_length"`
+	Chars            string           `json:"chars"`
+	Goos             string           `json:"goos"`
+	Entries          []manifest_entry `json:"entries"`
+}
+
+// A manifest_entry is one directory or file of the tree. Lengths are
+// counted as path_length counts them on the system that made the tree.
+type manifest_entry struct {
+	Path        string `json:"path"` // relative to the base path, with forward slashes
+	Kind        string `json:"kind"` // "dir" or "file"
+	Depth       int    `json:"depth"`
+	Name_length int    `json:"name_length"`
+	Path_length int    `json:"path_length"` // of the full path, base path included
+	Size        int64  `json:"size,omitempty"`
+}
+
+func new_manifest(seed uint64, target_name string, base_path string, target path_target, chars string) *manifest {
+	return &manifest{
+		Seed:             seed,
+		Target:           target_name,
+		Base_path:        base_path,
+		Total_length:     target.total_length,
+		Component_length: target.component_length,
+		Chars:            chars,
+		Goos:             runtime.GOOS,
+	}
+}
+
+// add_dirs records the chain of directories.
+func (m *manifest) add_dirs(components []string) {
+	full_path := m.Base_path
+	for depth, name := range components {
+		full_path = filepath.Join(full_path, name)
+		m.Entries = append(m.Entries, manifest_entry{
+			Path:        strings.Join(components[:depth+1], "/"),
+			Kind:        "dir",
+			Depth:       depth + 1,
+			Name_length: path_length(name),
+			Path_length: path_length(full_path),
+		})
+	}
+}
+
+// add_file records a file in the deepest directory.
+func (m *manifest) add_file(components []string, name string, size int64) {
+	full_path := filepath.Join(append(append([]string{m.Base_path}, components...), name)...)
+	m.Entries = append(m.Entries, manifest_entry{
+		Path:        strings.Join(append(append([]string{}, components...), name), "/"),
+		Kind:        "file",
+		Depth:       len(components) + 1,
+		Name_length: path_length(name),
+		Path_length: path_length(full_path),
+		Size:        size,
+	})
+}
+
+// components returns the names of the chain of directories, in order.
+func (m *manifest) components() []string {
+	var components []string
+	for _, entry := range m.Entries {
+		if entry.Kind == "dir" {
+			components = append(components, entry.Path[strings.LastIndex(entry.Path, "/")+1:])
+		}
+	}
+	return components
+}
+
+func write_manifest(filename string, m *manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+func read_manifest(filename string) (*manifest, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s is not a manifest: %w", filename, err)
+	}
+	return &m, nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Thresholds the verification checks just past, where the tree is that deep.
+var verify_thresholds = []int{260, 1024, 4096}
+
+const verify_content = "verify long paths\n"
+
+// A verify_column is a directory of the tree the operations are tried in:
+// the base path, the first directory past each threshold, and the deepest.
+type verify_column struct {
+	label string
+	path  string
+	depth int // number of components below the base path
+}
+
+// A verify_operation tries one file API by full path in dir. The fixtures
+// it needs are made beforehand through root, which reaches dir by relative
+// steps, so only the operation under test ever sees the long path.
+type verify_operation struct {
+	name string
+	run  func(root *os.Root, dir string, project_path string) error
+}
+
+var verify_operations = []verify_operation{
+	{"create", func(root *os.Root, dir string, _ string) error {
+		file, err := os.Create(filepath.Join(dir, "verify_create.txt"))
+		if err != nil {
+			return err
+		}
+		defer root.Remove("verify_create.txt")
+		return file.Close()
+	}},
+	{"read", func(root *os.Root, dir string, _ string) error {
+		if err := write_fixture(root, "verify_read.txt"); err != nil {
+			return err
+		}
+		defer root.Remove("verify_read.txt")
+		data, err := os.ReadFile(filepath.Join(dir, "verify_read.txt"))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(data, []byte(verify_content)) {
+			return fmt.Errorf("read %q, want %q", data, verify_content)
+		}
+		return nil
+	}},
+	{"rename", func(root *os.Root, dir string, _ string) error {
+		if err := write_fixture(root, "verify_rename.txt"); err != nil {
+			return err
+		}
+		defer root.Remove("verify_rename.txt")
+		defer root.Remove("verify_renamed.txt")
+		return os.Rename(filepath.Join(dir, "verify_rename.txt"), filepath.Join(dir, "verify_renamed.txt"))
+	}},
+	{"stat", func(root *os.Root, dir string, _ string) error {
+		if err := write_fixture(root, "verify_stat.txt"); err != nil {
+			return err
+		}
+		defer root.Remove("verify_stat.txt")
+		info, err := os.Stat(filepath.Join(dir, "verify_stat.txt"))
+		if err != nil {
+			return err
+		}
+		if info.Size() != int64(len(verify_content)) {
+			return fmt.Errorf("size %d, want %d", info.Size(), len(verify_content))
+		}
+		return nil
+	}},
+	{"chmod", func(root *os.Root, dir string, _ string) error {
+		if err := write_fixture(root, "verify_chmod.txt"); err != nil {
+			return err
+		}
+		defer root.Remove("verify_chmod.txt")
+		return os.Chmod(filepath.Join(dir, "verify_chmod.txt"), 0o600)
+	}},
+	{"symlink", func(root *os.Root, dir string, _ string) error {
+		if err := write_fixture(root, "verify_target.txt"); err != nil {
+			return err
+		}
+		defer root.Remove("verify_target.txt")
+		link := filepath.Join(dir, "verify_link")
+		if err := os.Symlink("verify_target.txt", link); err != nil {
+			return err
+		}
+		defer root.Remove("verify_link")
+		target, err := os.Readlink(link)
+		if err != nil {
+			return err
+		}
+		if target != "verify_target.txt" {
+			return fmt.Errorf("link points to %q", target)
+		}
+		return nil
+	}},
+	{"remove", func(root *os.Root, dir string, _ string) error {
+		if err := write_fixture(root, "verify_remove.txt"); err != nil {
+			return err
+		}
+		if err := os.Remove(filepath.Join(dir, "verify_remove.txt")); err != nil {
+			root.Remove("verify_remove.txt")
+			return err
+		}
+		return nil
+	}},
+	{"walk", func(root *os.Root, dir string, _ string) error {
+		if err := write_fixture(root, "verify_walk.txt"); err != nil {
+			return err
+		}
+		defer root.Remove("verify_walk.txt")
+		found := false
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() && path != dir {
+				return filepath.SkipDir // the rest of the tree is the next columns' business
+			}
+			if entry.Name() == "verify_walk.txt" {
+				found = true
+			}
+			return nil
+		})
+		if err == nil && !found {
+			err = errors.New("the walk did not reach verify_walk.txt")
+		}
+		return err
+	}},
+	{"go build", func(root *os.Root, dir string, project_path string) error {
+		for _, file := range []string{"go.mod", "main.go"} {
+			if _, err := root.Stat(file); err == nil {
+				continue
+			}
+			if err := copy_file(filepath.Join(project_path, file), root, file); err != nil {
+				return err
+			}
+			defer root.Remove(file) // only the copies made here; the deepest directory keeps its project
+		}
+		defer root.Remove("verify_build.exe")
+		cmd := exec.Command("go", "build", "-o", "verify_build.exe")
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if err != nil && len(bytes.TrimSpace(output)) > 0 {
+			return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+		}
+		return err
+	}},
+}
+
+func write_fixture(root *os.Root, name string) error {
+	file, err := root.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(verify_content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// verify_columns picks the directories to verify in.
+func verify_columns(base_path string, components []string) []verify_column {
+	columns := []verify_column{{label: "base", path: base_path}}
+	path := base_path
+	next := 0
+	for depth, name := range components {
+		path = filepath.Join(path, name)
+		length := path_length(path)
+		for next < len(verify_thresholds) && length > verify_thresholds[next] {
+			if depth+1 < len(components) {
+				columns = append(columns, verify_column{label: fmt.Sprintf(">%d", verify_thresholds[next]), path: path, depth: depth + 1})
+			}
+			next++
+		}
+	}
+	return append(columns, verify_column{label: "deepest", path: path, depth: len(components)})
+}
+
+// run_verify tries every operation in every column and prints a pass/fail
+// matrix, then the errors behind each failure. It reports whether all
+// passed.
+func run_verify(base_path string, components []string, project_path string) bool {
+	columns := verify_columns(base_path, components)
+	results := make([][]error, len(verify_operations))
+	for i := range results {
+		results[i] = make([]error, len(columns))
+	}
+	for j, column := range columns {
+		root, err := create_deep_path(base_path, components[:column.depth])
+		for i, operation := range verify_operations {
+			if err != nil {
+				results[i][j] = fmt.Errorf("could not open the directory to verify in: %w", err)
+				continue
+			}
+			results[i][j] = operation.run(root, column.path, project_path)
+		}
+		if root != nil {
+			root.Close()
+		}
+	}
+
+	fmt.Printf("\nVerification at each depth (path length in parentheses):\n")
+	fmt.Printf("%-10s", "operation")
+	for _, column := range columns {
+		fmt.Printf(" %-16s", fmt.Sprintf("%s (%d)", column.label, path_length(column.path)))
+	}
+	fmt.Println()
+	all_passed := true
+	for i, operation := range verify_operations {
+		fmt.Printf("%-10s", operation.name)
+		for j := range columns {
+			mark := "✅ pass"
+			if results[i][j] != nil {
+				mark = "❌ FAIL"
+				all_passed = false
+			}
+			fmt.Printf(" %-16s", mark)
+		}
+		fmt.Println()
+	}
+	for i, operation := range verify_operations {
+		for j, column := range columns {
+			if err := results[i][j]; err != nil {
+				fmt.Printf("❌ %s at %s: %s\n", operation.name, column.label, shorten_error(err, column.path))
+			}
+		}
+	}
+	return all_passed
+}
+
+// shorten_error replaces the long path in an error message with "…", since
+// it would otherwise bury the reason.
+func shorten_error(err error, path string) string {
+	message := err.Error()
+	if len(path) > 60 {
+		message = strings.ReplaceAll(message, path, "…")
+	}
+	return message
+}
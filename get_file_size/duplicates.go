@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// partial_hash_size is how much of each file the second pass hashes. Files
+// of the same size usually differ within their first few kilobytes.
+const partial_hash_size = 64 * 1024
+
+// Duplicate_set is a group of files with the same contents.
+type Duplicate_set struct {
+	Size        int64    `json:"size"`
+	Sha256      string   `json:"sha256"`
+	Paths       []string `json:"paths"`
+	Reclaimable int64    `json:"reclaimable"` // bytes freed by keeping one copy
+}
+
+// dup_candidate is a file that may have a copy elsewhere.
+type dup_candidate struct {
+	path    string
+	size    int64
+	partial string
+}
+
+// Find_duplicates returns the sets of files with the same contents under
+// paths, largest reclaimable space first. Files are grouped by size, then
+// by the SHA-256 of their first 64 KiB, and only the files still grouped
+// are hashed in full, so most files are never read at all.
+//
+// Empty files, symbolic links and files already hard-linked to each other
+// are not reported, since keeping one copy of them frees nothing. With
+// Follow_symlinks, a file reached through a symbolic link is listed by
+// the path of the file itself, never of the link, so Link_duplicates never
+// replaces a link.
+//
+// Parameters:
+//   - ctx: Stops the walk and the hashing when done.
+//   - paths: The files and directories to search, together.
+//   - options: How to walk them; On_file is used by Find_duplicates.
+//
+// Returns:
+//   - []Duplicate_set: The sets of identical files, each sorted by path.
+//   - error: As for Walk_tree_context; with Keep_going, a *Partial_error
+//     also lists the files that could not be hashed.
+//
+// Example:
+//
+//	sets, err := Find_duplicates(ctx, []string{`C:\downloads`}, Walk_options{Keep_going: true})
+//	for _, set := range sets {
+//	    fmt.Println(Format_size(set.Reclaimable, true), set.Paths)
+//	}
+func Find_duplicates(ctx context.Context, paths []string, options Walk_options) ([]Duplicate_set, error) {
+	var mu sync.Mutex
+	bySize := map[int64][]string{}
+	seen := map[file_key]bool{}
+	options.On_file = func(path string, info os.FileInfo, stat File_stat) {
+		if !info.Mode().IsRegular() || info.Size() == 0 {
+			return
+		}
+		if options.Follow_symlinks {
+			own, err := os.Lstat(path)
+			if err != nil {
+				return
+			}
+			if !own.Mode().IsRegular() {
+				// List the file the link leads to, which the walk may
+				// not reach again by its own name.
+				if path, err = filepath.EvalSymlinks(path); err != nil {
+					return
+				}
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if stat.Has_id {
+			key := file_key{stat.Device, stat.Inode}
+			if seen[key] {
+				return // another link to a file already listed
+			}
+			seen[key] = true
+		}
+		bySize[info.Size()] = append(bySize[info.Size()], path)
+	}
+
+	partial := &Partial_error{}
+	for _, path := range paths {
+		_, err := Walk_tree_context(ctx, path, options)
+		var walkPartial *Partial_error
+		if errors.As(err, &walkPartial) {
+			partial.Skipped = append(partial.Skipped, walkPartial.Skipped...)
+			partial.Cause = walkPartial.Cause
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if partial.Cause != nil {
+		return nil, partial
+	}
+
+	var candidates []dup_candidate
+	for size, files := range bySize {
+		if len(files) < 2 {
+			continue
+		}
+		for _, file := range files {
+			candidates = append(candidates, dup_candidate{path: file, size: size})
+		}
+	}
+
+	hasher := &dup_hasher{ctx: ctx, options: options, partial: partial}
+	hasher.run(candidates, func(c *dup_candidate) error {
+		sum, err := hash_file(c.path, partial_hash_size)
+		c.partial = sum
+		return err
+	})
+	groups := map[string][]dup_candidate{}
+	for _, c := range hasher.done {
+		key := fmt.Sprintf("%d/%s", c.size, c.partial)
+		groups[key] = append(groups[key], c)
+	}
+
+	candidates = candidates[:0]
+	for _, group := range groups {
+		if len(group) > 1 {
+			candidates = append(candidates, group...)
+		}
+	}
+	hasher.done = nil
+	hasher.run(candidates, func(c *dup_candidate) error {
+		if c.size <= partial_hash_size {
+			return nil // the partial hash covered the whole file
+		}
+		sum, err := hash_file(c.path, -1)
+		c.partial = sum
+		return err
+	})
+	if hasher.err != nil {
+		return nil, hasher.err
+	}
+
+	full := map[string]*Duplicate_set{}
+	for _, c := range hasher.done {
+		key := fmt.Sprintf("%d/%s", c.size, c.partial)
+		set := full[key]
+		if set == nil {
+			set = &Duplicate_set{Size: c.size, Sha256: c.partial}
+			full[key] = set
+		}
+		set.Paths = append(set.Paths, c.path)
+	}
+	sets := []Duplicate_set{}
+	for _, set := range full {
+		if len(set.Paths) < 2 {
+			continue
+		}
+		sort.Strings(set.Paths)
+		set.Reclaimable = set.Size * int64(len(set.Paths)-1)
+		sets = append(sets, *set)
+	}
+	sort.Slice(sets, func(i, j int) bool {
+		if sets[i].Reclaimable != sets[j].Reclaimable {
+			return sets[i].Reclaimable > sets[j].Reclaimable
+		}
+		return sets[i].Paths[0] < sets[j].Paths[0]
+	})
+
+	if ctx.Err() != nil || len(partial.Skipped) > 0 {
+		partial.Cause = ctx.Err()
+		sort.Slice(partial.Skipped, func(i, j int) bool { return partial.Skipped[i].Path < partial.Skipped[j].Path })
+		return sets, partial
+	}
+	return sets, nil
+}
+
+// dup_hasher hashes candidates in parallel, keeping those it could read.
+type dup_hasher struct {
+	ctx     context.Context
+	options Walk_options
+	partial *Partial_error
+
+	mu   sync.Mutex
+	done []dup_candidate
+	err  error
+}
+
+func (h *dup_hasher) run(candidates []dup_candidate, hash func(*dup_candidate) error) {
+	workers := h.options.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	jobs := make(chan dup_candidate)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				err := hash(&c)
+				h.mu.Lock()
+				switch {
+				case err == nil:
+					h.done = append(h.done, c)
+				case h.options.Keep_going:
+					h.partial.Skipped = append(h.partial.Skipped, Skipped_entry{Path: c.path, Error: err.Error(), Err: err})
+				case h.err == nil:
+					h.err = err
+				}
+				h.mu.Unlock()
+			}
+		}()
+	}
+	for _, c := range candidates {
+		if h.ctx.Err() != nil {
+			break
+		}
+		jobs <- c
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// hash_file returns the hex SHA-256 of the first limit bytes of the file,
+// or of all of it when limit is negative.
+func hash_file(path string, limit int64) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if limit >= 0 {
+		reader = io.LimitReader(file, limit)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Link_duplicates replaces every copy in set after the first with a hard
+// link to the first, and returns the bytes freed. Each copy is compared
+// byte for byte with the first just before it is replaced, and replaced by
+// renaming a new link over it, so a copy that changed since the scan is
+// left alone and none is ever missing.
+//
+// Only regular files are replaced, never symbolic links, and only copies
+// with the same permissions and owner as the first, since the copies
+// would otherwise all take on the first's. A copy counts as freed only if
+// no other hard link keeps its data on the disk.
+//
+// Returns:
+//   - int64: The bytes freed.
+//   - []Skipped_entry: The copies that were not replaced, and why; hard
+//     links cannot cross file systems, for one.
+func Link_duplicates(set Duplicate_set) (int64, []Skipped_entry) {
+	keep := set.Paths[0]
+	var freed int64
+	var skipped []Skipped_entry
+	for _, path := range set.Paths[1:] {
+		released, err := replace_with_link(keep, path)
+		if err != nil {
+			skipped = append(skipped, Skipped_entry{Path: path, Error: err.Error(), Err: err})
+			continue
+		}
+		if released {
+			freed += set.Size
+		}
+	}
+	return freed, skipped
+}
+
+// replace_with_link replaces path with a hard link to keep, and reports
+// whether that removed the last link to path's data.
+func replace_with_link(keep, path string) (bool, error) {
+	keepInfo, err := os.Lstat(keep)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case !keepInfo.Mode().IsRegular():
+		return false, fmt.Errorf("%s is not a regular file", keep)
+	case !info.Mode().IsRegular():
+		return false, fmt.Errorf("%s is not a regular file", path)
+	case os.SameFile(keepInfo, info):
+		return false, nil // already linked
+	case info.Mode() != keepInfo.Mode():
+		return false, fmt.Errorf("mode %v differs from %v of %s", info.Mode(), keepInfo.Mode(), keep)
+	case Owner_id(path, info) != Owner_id(keep, keepInfo):
+		return false, fmt.Errorf("owner differs from that of %s", keep)
+	}
+
+	links := Stat_of(path, info).Links
+
+	same, err := same_contents(keep, path)
+	if err != nil {
+		return false, err
+	}
+	if !same {
+		return false, fmt.Errorf("%s no longer matches %s", path, keep)
+	}
+	temp := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.%d.link", filepath.Base(path), os.Getpid()))
+	if err := os.Link(keep, temp); err != nil {
+		return false, err
+	}
+	if err := os.Rename(temp, path); err != nil {
+		os.Remove(temp)
+		return false, err
+	}
+	return links <= 1, nil
+}
+
+// same_contents reports whether the two files hold the same bytes.
+func same_contents(a, b string) (bool, error) {
+	fileA, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fileA.Close()
+	fileB, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fileB.Close()
+
+	bufA := make([]byte, partial_hash_size)
+	bufB := make([]byte, partial_hash_size)
+	for {
+		nA, errA := io.ReadFull(fileA, bufA)
+		nB, errB := io.ReadFull(fileB, bufB)
+		if nA != nB || !bytes.Equal(bufA[:nA], bufB[:nB]) {
+			return false, nil
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil {
+			return false, errB
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// make_duplicate_tree writes a tree with two copies of a large file, one
+// of them also reached by a hard link and a symbolic link, a file of the
+// same size that differs past the partial hash, and two copies of a small
+// file. It returns the root of the tree and the size of the large file.
+func make_duplicate_tree(t *testing.T) (string, int64) {
+	t.Helper()
+	root := t.TempDir()
+	large := bytes.Repeat([]byte("0123456789abcdef"), 8*1024) // twice the partial hash
+	differs := slices.Clone(large)
+	differs[len(differs)-1] = '!'
+	files := map[string][]byte{
+		"a/one.bin":    large,
+		"b/two.bin":    large,
+		"d/other.bin":  differs,
+		"small1.txt":   []byte("hello\n"),
+		"e/small2.txt": []byte("hello\n"),
+		"empty1":       nil,
+		"empty2":       nil,
+	}
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Link(filepath.Join(root, "a/one.bin"), filepath.Join(root, "b/hard.bin")); err != nil {
+		t.Skipf("hard links are not supported here: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "c"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("..", "a", "one.bin"), filepath.Join(root, "c/link.bin")); err != nil {
+		t.Skipf("symbolic links are not supported here: %v", err)
+	}
+	return root, int64(len(large))
+}
+
+// relative_sets returns the paths of each set relative to root.
+func relative_sets(t *testing.T, root string, sets []Duplicate_set) [][]string {
+	t.Helper()
+	var result [][]string
+	for _, set := range sets {
+		var paths []string
+		for _, path := range set.Paths {
+			paths = append(paths, relative_slash(root, path))
+		}
+		result = append(result, paths)
+	}
+	return result
+}
+
+func TestFind_duplicates(t *testing.T) {
+	root, size := make_duplicate_tree(t)
+	for _, follow := range []bool{false, true} {
+		sets, err := Find_duplicates(context.Background(), []string{root}, Walk_options{Follow_symlinks: follow})
+		if err != nil {
+			t.Fatalf("Find_duplicates (follow %v): %v", follow, err)
+		}
+		got := relative_sets(t, root, sets)
+		if len(got) != 2 {
+			t.Fatalf("Find_duplicates (follow %v) = %v, want two sets", follow, got)
+		}
+
+		// The file with two names is listed once, by either name, and
+		// never by the symbolic link.
+		large := got[0]
+		if len(large) != 2 || large[1] != "b/two.bin" || large[0] != "a/one.bin" && large[0] != "b/hard.bin" {
+			t.Errorf("large set (follow %v) = %v, want a/one.bin or b/hard.bin, and b/two.bin", follow, large)
+		}
+		if sets[0].Size != size || sets[0].Reclaimable != size {
+			t.Errorf("large set (follow %v): size %d, reclaimable %d, want %d both", follow, sets[0].Size, sets[0].Reclaimable, size)
+		}
+		if want := []string{"e/small2.txt", "small1.txt"}; !slices.Equal(got[1], want) {
+			t.Errorf("small set (follow %v) = %v, want %v", follow, got[1], want)
+		}
+	}
+}
+
+func TestLink_duplicates(t *testing.T) {
+	root, size := make_duplicate_tree(t)
+	sets, err := Find_duplicates(context.Background(), []string{root}, Walk_options{Follow_symlinks: true})
+	if err != nil || len(sets) != 2 {
+		t.Fatalf("Find_duplicates = %v, %v, want two sets", sets, err)
+	}
+
+	freed, skipped := Link_duplicates(sets[0])
+	if freed != size || len(skipped) != 0 {
+		t.Errorf("Link_duplicates = %d, %v, want %d freed and none skipped", freed, skipped, size)
+	}
+	one, _ := os.Stat(filepath.Join(root, "a/one.bin"))
+	two, _ := os.Stat(filepath.Join(root, "b/two.bin"))
+	if !os.SameFile(one, two) {
+		t.Errorf("b/two.bin is not a hard link to a/one.bin")
+	}
+	if info, err := os.Lstat(filepath.Join(root, "c/link.bin")); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("c/link.bin is no longer a symbolic link: %v, %v", info, err)
+	}
+
+	// Linking again finds the copies linked already and frees nothing.
+	if freed, skipped := Link_duplicates(sets[0]); freed != 0 || len(skipped) != 0 {
+		t.Errorf("Link_duplicates again = %d, %v, want nothing", freed, skipped)
+	}
+
+	// A copy with other permissions is left alone.
+	if runtime.GOOS == "windows" {
+		return // Windows keeps no Unix permissions to differ
+	}
+	other := filepath.Join(root, "small1.txt") // e/small2.txt sorts first and is kept
+	if err := os.Chmod(other, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	freed, skipped = Link_duplicates(sets[1])
+	if freed != 0 || len(skipped) != 1 || skipped[0].Path != other {
+		t.Errorf("Link_duplicates with another mode = %d, %v, want %s skipped", freed, skipped, other)
+	}
+}
+
+func TestLink_duplicates_symlink(t *testing.T) {
+	root, _ := make_duplicate_tree(t)
+	link := filepath.Join(root, "c/link.bin")
+	set := Duplicate_set{Paths: []string{filepath.Join(root, "b/two.bin"), link}}
+	freed, skipped := Link_duplicates(set)
+	if freed != 0 || len(skipped) != 1 {
+		t.Errorf("Link_duplicates over a symbolic link = %d, %v, want it skipped", freed, skipped)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("%s is no longer a symbolic link: %v, %v", link, info, err)
+	}
+}
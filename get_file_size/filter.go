@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Filter chooses the files a walk counts and the directories it enters.
+//
+// Glob patterns use the syntax of path.Match. A pattern without a slash
+// is matched against the name of each entry ("*.exe", "node_modules"); one
+// with a slash against its path relative to the root, written with forward
+// slashes ("build/*/obj"). Regular expressions are always matched against
+// that relative path.
+//
+// Exclusions apply to files and directories alike, and an excluded
+// directory is not entered at all. Every other criterion applies to files
+// only: when Include or Include_regex is given a file must match one of
+// them, and its size and modification time must fall within the limits.
+type Filter struct {
+	Include       []string
+	Exclude       []string
+	Include_regex []*regexp.Regexp
+	Exclude_regex []*regexp.Regexp
+
+	Min_size int64 // zero for no lower limit
+	Max_size int64 // zero for no upper limit
+
+	Modified_after  time.Time // the zero time for no limit
+	Modified_before time.Time // the zero time for no limit
+
+	// Newer and Older are the -newer and -older values as given, such as
+	// 7d, which a snapshot records instead of the times they stood for
+	// when it was taken. Empty when the times were set directly.
+	Newer string
+	Older string
+}
+
+// Validate reports a malformed glob pattern, which path.Match would
+// otherwise only report on the first name it meets.
+func (f *Filter) Validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad pattern %q: %w", pattern, err)
+		}
+	}
+	if f.Max_size > 0 && f.Min_size > f.Max_size {
+		return fmt.Errorf("minimum size %d is larger than maximum size %d", f.Min_size, f.Max_size)
+	}
+	return nil
+}
+
+// selects_files reports whether the filter leaves out some files for more
+// than their location, in which case the blocks of the directories
+// themselves are not counted either.
+func (f *Filter) selects_files() bool {
+	return f != nil && (len(f.Include) > 0 || len(f.Include_regex) > 0 ||
+		f.Min_size > 0 || f.Max_size > 0 ||
+		!f.Modified_after.IsZero() || !f.Modified_before.IsZero())
+}
+
+// Excluded reports whether the entry at relative path rel is excluded.
+func (f *Filter) Excluded(rel string) bool {
+	if f == nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	return match_any(f.Exclude, rel) || regex_any(f.Exclude_regex, rel)
+}
+
+// Selected reports whether the file at relative path rel, with info, is
+// to be counted. Exclusions are checked separately by Excluded.
+func (f *Filter) Selected(rel string, info os.FileInfo) bool {
+	if f == nil {
+		return true
+	}
+	rel = filepath.ToSlash(rel)
+	if (len(f.Include) > 0 || len(f.Include_regex) > 0) &&
+		!match_any(f.Include, rel) && !regex_any(f.Include_regex, rel) {
+		return false
+	}
+	if info.Size() < f.Min_size || f.Max_size > 0 && info.Size() > f.Max_size {
+		return false
+	}
+	modified := info.ModTime()
+	if !f.Modified_after.IsZero() && !modified.After(f.Modified_after) {
+		return false
+	}
+	if !f.Modified_before.IsZero() && !modified.Before(f.Modified_before) {
+		return false
+	}
+	return true
+}
+
+func match_any(patterns []string, rel string) bool {
+	name := path.Base(rel)
+	for _, pattern := range patterns {
+		subject := name
+		if strings.Contains(pattern, "/") {
+			subject = rel
+		}
+		if matched, _ := path.Match(pattern, subject); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func regex_any(patterns []*regexp.Regexp, rel string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// Group_stats is the total of the files that share an extension or an
+// owner.
+type Group_stats struct {
+	Key   string `json:"key"`
+	Files int64  `json:"files"`
+	Size  int64  `json:"size"`
+}
+
+// Walk_stats are the totals of a walk grouped by file extension and by
+// owner. The sizes are measured the same way as Size_node.Size.
+type Walk_stats struct {
+	By_extension []Group_stats `json:"by_extension,omitempty"`
+	By_owner     []Group_stats `json:"by_owner,omitempty"`
+}
+
+// no_extension is the key of files whose name has no extension.
+const no_extension = "(none)"
+
+// Extension_of returns the key a file is grouped under by extension: the
+// extension in lower case, so that .EXE and .exe add up, or "(none)".
+func Extension_of(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == "" || ext == name {
+		return no_extension // no dot, or a dot file such as .gitignore
+	}
+	return ext
+}
+
+// group_counter collects Group_stats by key.
+type group_counter map[string]*Group_stats
+
+func (g group_counter) add(key string, files, size int64) {
+	stats := g[key]
+	if stats == nil {
+		stats = &Group_stats{Key: key}
+		g[key] = stats
+	}
+	stats.Files += files
+	stats.Size += size
+}
+
+// sorted returns the groups, largest first.
+func (g group_counter) sorted() []Group_stats {
+	groups := make([]Group_stats, 0, len(g))
+	for _, stats := range g {
+		groups = append(groups, *stats)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Size != groups[j].Size {
+			return groups[i].Size > groups[j].Size
+		}
+		return groups[i].Key < groups[j].Key
+	})
+	return groups
+}
+
+// owner_names caches the names Owner_of looks up, since a tree usually
+// belongs to a handful of users.
+var owner_names sync.Map
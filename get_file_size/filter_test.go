@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io/fs"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// fake_info is an os.FileInfo for a file that does not exist.
+type fake_info struct {
+	size     int64
+	modified time.Time
+}
+
+func (f fake_info) Name() string       { return "" }
+func (f fake_info) Size() int64        { return f.size }
+func (f fake_info) Mode() fs.FileMode  { return 0o644 }
+func (f fake_info) ModTime() time.Time { return f.modified }
+func (f fake_info) IsDir() bool        { return false }
+func (f fake_info) Sys() any           { return nil }
+
+func TestFilter_excluded(t *testing.T) {
+	filter := &Filter{
+		Exclude:       []string{"node_modules", "*.tmp", "build/*/obj"},
+		Exclude_regex: []*regexp.Regexp{regexp.MustCompile(`(^|/)\.git$`)},
+	}
+	tests := []struct {
+		rel  string
+		want bool
+	}{
+		{"node_modules", true},
+		{"web/node_modules", true},
+		{"web/node_modules.txt", false},
+		{"cache/a.tmp", true},
+		{"a.tmp.keep", false},
+		{"build/x64/obj", true},
+		{"src/build/x64/obj", false},
+		{"build/obj", false},
+		{".git", true},
+		{"sub/.git", true},
+		{".github", false},
+		{"main.go", false},
+	}
+	for _, tt := range tests {
+		if got := filter.Excluded(tt.rel); got != tt.want {
+			t.Errorf("Excluded(%q) = %v, want %v", tt.rel, got, tt.want)
+		}
+	}
+	if (*Filter)(nil).Excluded("anything") {
+		t.Errorf("a nil filter excluded a path")
+	}
+}
+
+func TestFilter_selected(t *testing.T) {
+	day := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	filter := &Filter{
+		Include:         []string{"*.go", "docs/*.md"},
+		Include_regex:   []*regexp.Regexp{regexp.MustCompile(`\.ya?ml$`)},
+		Min_size:        10,
+		Max_size:        1000,
+		Modified_after:  day,
+		Modified_before: day.AddDate(0, 1, 0),
+	}
+	recent := day.AddDate(0, 0, 7)
+	tests := []struct {
+		rel  string
+		info fake_info
+		want bool
+	}{
+		{"main.go", fake_info{100, recent}, true},
+		{"cmd/tool/main.go", fake_info{100, recent}, true},
+		{"docs/readme.md", fake_info{100, recent}, true},
+		{"readme.md", fake_info{100, recent}, false},
+		{"config/app.yaml", fake_info{100, recent}, true},
+		{"main.c", fake_info{100, recent}, false},
+		{"small.go", fake_info{9, recent}, false},
+		{"edge.go", fake_info{10, recent}, true},
+		{"large.go", fake_info{1001, recent}, false},
+		{"old.go", fake_info{100, day}, false},
+		{"new.go", fake_info{100, day.AddDate(0, 1, 0)}, false},
+	}
+	for _, tt := range tests {
+		if got := filter.Selected(tt.rel, tt.info); got != tt.want {
+			t.Errorf("Selected(%q, %d bytes, %v) = %v, want %v", tt.rel, tt.info.size, tt.info.modified, got, tt.want)
+		}
+	}
+	if !(*Filter)(nil).Selected("anything", fake_info{}) {
+		t.Errorf("a nil filter left a file out")
+	}
+}
+
+func TestFilter_validate(t *testing.T) {
+	for _, filter := range []*Filter{
+		{Include: []string{"[a-"}},
+		{Exclude: []string{"\\"}},
+		{Min_size: 10, Max_size: 5},
+	} {
+		if err := filter.Validate(); err == nil {
+			t.Errorf("Validate(%+v): want an error", filter)
+		}
+	}
+	if err := (&Filter{Include: []string{"*.go"}, Min_size: 5, Max_size: 5}).Validate(); err != nil {
+		t.Errorf("Validate of a good filter: %v", err)
+	}
+}
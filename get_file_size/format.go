@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format_size renders a byte count, in IEC units (KiB, MiB, ...) with one
+// decimal when human is true, or as a plain number of bytes otherwise.
+//
+// Example:
+//
+//	Format_size(1536, true)  // "1.5 KiB"
+//	Format_size(1536, false) // "1536"
+func Format_size(size int64, human bool) string {
+	if !human {
+		return fmt.Sprintf("%d", size)
+	}
+	const unit = 1024
+	if size < unit && size > -unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size)
+	units := []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+	i := -1
+	for (value >= unit || value <= -unit) && i < len(units)-1 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
+
+// Print_tree writes one line per node, size then path, in the order du
+// uses: every directory after the directories inside it.
+func Print_tree(out io.Writer, node *Size_node, human bool) {
+	for _, child := range node.Children {
+		Print_tree(out, child, human)
+	}
+	fmt.Fprintf(out, "%-12s %s\n", Format_size(node.Size, human), node.Path)
+}
+
+// Print_tree_json writes the tree as indented JSON.
+func Print_tree_json(out io.Writer, node *Size_node) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(node)
+}
+
+// Parse_size reads a size such as "1500", "10K", "1.5MiB" or "2 GB". The
+// units are powers of 1024 whichever way they are written, as in
+// Format_size.
+func Parse_size(text string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(text))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	multiplier := int64(1)
+	if s != "" {
+		if i := strings.IndexByte("KMGTPE", s[len(s)-1]); i >= 0 {
+			multiplier = int64(1) << (10 * (i + 1))
+			s = s[:len(s)-1]
+		}
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", text)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// Parse_time reads a point in time given as a date ("2025-01-31"), a date
+// and time in RFC 3339, or an age counted back from now ("36h", "7d").
+func Parse_time(text string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(text, "d"); ok {
+		if n, err := strconv.ParseFloat(days, 64); err == nil {
+			return now.Add(-time.Duration(n * 24 * float64(time.Hour))), nil
+		}
+	}
+	if age, err := time.ParseDuration(text); err == nil {
+		return now.Add(-age), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: want a date such as 2025-01-31 or an age such as 36h or 7d", text)
+}
+
+// Print_stats writes a table of groups, largest first, under a title.
+func Print_stats(out io.Writer, title string, groups []Group_stats, human bool) {
+	fmt.Fprintf(out, "%s:\n", title)
+	for _, group := range groups {
+		fmt.Fprintf(out, "  %-12s %8d files  %s\n", Format_size(group.Size, human), group.Files, group.Key)
+	}
+}
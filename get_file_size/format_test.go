@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_size(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"1500", 1500},
+		{"10K", 10 << 10},
+		{"10k", 10 << 10},
+		{"1.5MiB", 3 << 19},
+		{"2 GB", 2 << 30},
+		{" 3T ", 3 << 40},
+		{"1E", 1 << 60},
+		{"512B", 512},
+	}
+	for _, tt := range tests {
+		got, err := Parse_size(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("Parse_size(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "K", "-1", "ten", "10X", "1.5.2M"} {
+		if got, err := Parse_size(bad); err == nil {
+			t.Errorf("Parse_size(%q) = %d, want an error", bad, got)
+		}
+	}
+}
+
+func TestParse_time(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"36h", now.Add(-36 * time.Hour)},
+		{"90m", now.Add(-90 * time.Minute)},
+		{"7d", now.AddDate(0, 0, -7)},
+		{"0.5d", now.Add(-12 * time.Hour)},
+		{"2025-01-31", time.Date(2025, 1, 31, 0, 0, 0, 0, time.Local)},
+		{"2025-01-31 08:30:00", time.Date(2025, 1, 31, 8, 30, 0, 0, time.Local)},
+		{"2025-01-31T08:30:00", time.Date(2025, 1, 31, 8, 30, 0, 0, time.Local)},
+		{"2025-01-31T08:30:00Z", time.Date(2025, 1, 31, 8, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := Parse_time(tt.in, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("Parse_time(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "yesterday", "d", "2025-13-01", "31/01/2025"} {
+		if got, err := Parse_time(bad, now); err == nil {
+			t.Errorf("Parse_time(%q) = %v, want an error", bad, got)
+		}
+	}
+}
@@ -2,15 +2,24 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"regexp"
+	"strings"
+	"time"
 )
 
 // Get_file_size returns the size in bytes of the specified path.
 // If the path is a regular file, its size is returned directly.
 // If the path is a directory, the function walks through all files
 // and returns the cumulative size of all non-directory files within it.
+// A file with several hard links inside the directory is counted once.
 //
 // Parameters:
 //   - path: The path to the file or directory.
@@ -20,49 +29,370 @@ import (
 //   - error: Any error encountered while accessing the file system.
 //
 // Example:
-//   size, err := Get_file_size("C:\\Users\\Administrator\\Desktop")
-//   if err != nil {
-//       log.Fatal(err)
-//   }
-//   fmt.Println("Total size:", size)
+//
+//	size, err := Get_file_size("C:\\Users\\Administrator\\Desktop")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	fmt.Println("Total size:", size)
 func Get_file_size(path string) (int64, error) {
-	var totalSize int64
-
-	info, err := os.Stat(path)
+	root, err := Walk_tree(path, Walk_options{})
 	if err != nil {
 		return 0, err
 	}
+	return root.Size, nil
+}
 
-	if !info.IsDir() {
-		return info.Size(), nil
+// main is the entry point of the program. It prints the total size of
+// each path given, the current directory by default:
+//
+//	get_file_size [flags] [path ...]
+//
+// The flags, in groups:
+//
+//   - -depth, -sort, -human, -json: a du-style breakdown of where the
+//     space goes.
+//   - -allocated: count the disk blocks used, as du does by default,
+//     instead of the apparent size.
+//   - -count-links, -L, -x: count every hard link to a file, follow
+//     symbolic links, or stay on one file system, as du -l, -L and -x do.
+//   - -strict, -timeout: stop at the first unreadable entry, or after a
+//     while. Otherwise unreadable entries are skipped and listed on stderr.
+//   - -include, -exclude, -include-regex, -exclude-regex, -min-size,
+//     -max-size, -newer, -older: choose what is counted.
+//   - -by-ext, -by-owner: totals by file type and by user.
+//   - -duplicates, -link, -yes: list files with identical contents, and
+//     replace the copies with hard links.
+//   - -snapshot, -diff, -hash: record a tree in a file, and compare the
+//     tree with such a file.
+//
+// The exit status is 1 when anything was skipped or the walk stopped early.
+func main() {
+	depth := flag.Int("depth", 0, "print directories down to this depth below each path (-1 for all)")
+	sortBy := flag.String("sort", "size", "order of directories: size (largest first) or name")
+	human := flag.Bool("human", false, "print sizes in KiB, MiB, GiB, ...")
+	jsonOutput := flag.Bool("json", false, "print the tree as JSON")
+	workers := flag.Int("workers", 0, "directories read in parallel (default four per CPU)")
+	allocated := flag.Bool("allocated", false, "measure disk space used (blocks) instead of apparent size")
+	countLinks := flag.Bool("count-links", false, "count a file once per hard link instead of once")
+	followSymlinks := flag.Bool("L", false, "follow symbolic links")
+	oneFileSystem := flag.Bool("x", false, "skip directories on other file systems")
+	strict := flag.Bool("strict", false, "stop at the first entry that cannot be read")
+	timeout := flag.Duration("timeout", 0, "stop walking after this long and report what was counted (0 for no limit)")
+	var include, exclude, includeRegex, excludeRegex string_list
+	flag.Var(&include, "include", "count only files matching this glob (repeatable)")
+	flag.Var(&exclude, "exclude", "skip files and directories matching this glob, such as .git or node_modules (repeatable)")
+	flag.Var(&includeRegex, "include-regex", "count only files whose relative path matches this regexp (repeatable)")
+	flag.Var(&excludeRegex, "exclude-regex", "skip entries whose relative path matches this regexp (repeatable)")
+	minSize := flag.String("min-size", "", "count only files at least this big, such as 10M")
+	maxSize := flag.String("max-size", "", "count only files at most this big")
+	newer := flag.String("newer", "", "count only files modified after this date or within this age, such as 2025-01-31 or 7d")
+	older := flag.String("older", "", "count only files modified before this date or more than this age ago")
+	byExt := flag.Bool("by-ext", false, "print totals by file extension")
+	byOwner := flag.Bool("by-owner", false, "print totals by owner")
+	duplicates := flag.Bool("duplicates", false, "list sets of files with identical contents")
+	link := flag.Bool("link", false, "with -duplicates, replace each copy with a hard link to the first")
+	yes := flag.Bool("yes", false, "with -link, do not ask for confirmation")
+	snapshotFile := flag.String("snapshot", "", "save a snapshot of the path to this file")
+	diffFile := flag.String("diff", "", "compare the path with the snapshot in this file")
+	hash := flag.Bool("hash", false, "with -snapshot, record the SHA-256 of every file")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: get_file_size [flags] [path ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *sortBy != "size" && *sortBy != "name" {
+		fmt.Fprintf(os.Stderr, "❌ Error: -sort must be size or name, not %q\n", *sortBy)
+		os.Exit(2)
+	}
+	filter, err := build_filter(include, exclude, includeRegex, excludeRegex, *minSize, *maxSize, *newer, *older)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error: %v\n", err)
+		os.Exit(2)
 	}
 
-	err = filepath.Walk(path, func(_ string, fileInfo os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	paths := flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	options := Walk_options{
+		Workers:         *workers,
+		Allocated:       *allocated,
+		Count_links:     *countLinks,
+		Follow_symlinks: *followSymlinks,
+		One_file_system: *oneFileSystem,
+		Keep_going:      !*strict,
+		Filter:          filter,
+		Extension_stats: *byExt,
+		Owner_stats:     *byOwner,
+	}
+	if *snapshotFile != "" || *diffFile != "" {
+		if len(paths) != 1 {
+			fmt.Fprintf(os.Stderr, "❌ Error: -snapshot and -diff take a single path\n")
+			os.Exit(2)
+		}
+		if !run_snapshot(ctx, paths[0], options, *snapshotFile, *diffFile, *hash, *human, *jsonOutput) {
+			os.Exit(1)
+		}
+		return
+	}
+	if *duplicates {
+		if !run_duplicates(ctx, paths, options, *human, *jsonOutput, *link, *yes) {
+			os.Exit(1)
 		}
-		if !fileInfo.IsDir() {
-			totalSize += fileInfo.Size()
+		return
+	}
+
+	failed := false
+	for _, path := range paths {
+		root, err := Walk_tree_context(ctx, path, options)
+		var partial *Partial_error
+		if errors.As(err, &partial) {
+			print_skipped(partial.Skipped)
+			if partial.Cause != nil {
+				fmt.Fprintf(os.Stderr, "⏱️ Stopped early (%v); sizes below are partial\n", partial.Cause)
+			}
+			failed = true
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error: %v\n", err)
+			failed = true
+			continue
 		}
-		return nil
-	})
+		Sort_tree(root, *sortBy)
+		Prune_tree(root, *depth)
 
-	if err != nil {
-		return 0, err
+		switch {
+		case *jsonOutput:
+			if err := Print_tree_json(os.Stdout, root); err != nil {
+				fmt.Fprintf(os.Stderr, "❌ Error: %v\n", err)
+				failed = true
+			}
+		case *depth != 0:
+			Print_tree(os.Stdout, root, *human)
+		case *human:
+			fmt.Printf("📦 Total size of '%s': %s\n", path, Format_size(root.Size, true))
+		default:
+			fmt.Printf("📦 Total size of '%s': %d bytes\n", path, root.Size)
+		}
+		if root.Stats != nil && !*jsonOutput {
+			if *byExt {
+				Print_stats(os.Stdout, "By extension", root.Stats.By_extension, *human)
+			}
+			if *byOwner {
+				Print_stats(os.Stdout, "By owner", root.Stats.By_owner, *human)
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
 	}
+}
 
-	return totalSize, nil
+// print_skipped lists on stderr the entries a walk could not read.
+func print_skipped(skipped []Skipped_entry) {
+	for _, entry := range skipped {
+		fmt.Fprintf(os.Stderr, "⚠️ Skipped '%s': %s\n", entry.Path, entry.Error)
+	}
 }
 
-// main is the entry point of the program.
-// It demonstrates the usage of Get_file_size by printing
-// the size of a predefined path.
-func main() {
-	path := `C:\Users\Administrator\Desktop\GitHub-repositories\configuration-003\go_projects\configuration\apps\configure_keyboard_shortcuts_for_vs_code\main.go`
-	size, err := Get_file_size(path)
-	if err != nil {
-		fmt.Printf("❌ Error: %v\n", err)
-		return
+// run_duplicates prints the duplicate sets under paths and, with link,
+// replaces the copies with hard links. It reports whether everything went
+// well.
+func run_duplicates(ctx context.Context, paths []string, options Walk_options, human, jsonOutput, link, yes bool) bool {
+	size := func(n int64) string {
+		if human {
+			return Format_size(n, true)
+		}
+		return fmt.Sprintf("%d bytes", n)
+	}
+	ok := true
+	sets, err := Find_duplicates(ctx, paths, options)
+	var partial *Partial_error
+	if errors.As(err, &partial) {
+		print_skipped(partial.Skipped)
+		if partial.Cause != nil {
+			fmt.Fprintf(os.Stderr, "⏱️ Stopped early (%v); no duplicates reported\n", partial.Cause)
+			return false
+		}
+		ok = false
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error: %v\n", err)
+		return false
+	}
+
+	var reclaimable int64
+	for _, set := range sets {
+		reclaimable += set.Reclaimable
+	}
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(sets); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error: %v\n", err)
+			return false
+		}
+	} else {
+		for _, set := range sets {
+			fmt.Printf("🔁 %d copies of %s (sha256 %s), %s reclaimable:\n",
+				len(set.Paths), size(set.Size), set.Sha256[:12], size(set.Reclaimable))
+			for _, path := range set.Paths {
+				fmt.Printf("   %s\n", path)
+			}
+		}
+		fmt.Printf("♻️ %s reclaimable in %d sets of duplicates\n", size(reclaimable), len(sets))
 	}
-	fmt.Printf("📦 Total size of '%s': %d bytes\n", path, size)
-}
\ No newline at end of file
+	if !link || len(sets) == 0 {
+		return ok
+	}
+
+	if !yes {
+		fmt.Fprintf(os.Stderr, "Replace the copies with hard links to the first file of each set? [y/N] ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			fmt.Fprintln(os.Stderr, "Nothing replaced.")
+			return ok
+		}
+	}
+	var freed int64
+	for _, set := range sets {
+		setFreed, skipped := Link_duplicates(set)
+		freed += setFreed
+		if len(skipped) > 0 {
+			print_skipped(skipped)
+			ok = false
+		}
+	}
+	fmt.Printf("🔗 Freed %s by hard-linking duplicates\n", size(freed))
+	return ok
+}
+
+// run_snapshot compares path with the snapshot in diffFile and saves a
+// snapshot of it to snapshotFile, whichever are given; the snapshot is
+// hashed if -hash is given or the old one was. It reports whether
+// everything went well.
+func run_snapshot(ctx context.Context, path string, options Walk_options, snapshotFile, diffFile string, hash, human, jsonOutput bool) bool {
+	var old *Snapshot
+	if diffFile != "" {
+		var err error
+		if old, err = Load_snapshot(diffFile); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error: %v\n", err)
+			return false
+		}
+		hash = hash || old.Hashed
+		if differences := old.Options.Differences(Snapshot_options_of(options)); len(differences) > 0 {
+			fmt.Fprintf(os.Stderr, "❌ Error: '%s' was taken with other options (%s); give the same ones to compare with it\n",
+				diffFile, strings.Join(differences, ", "))
+			return false
+		}
+	}
+
+	snapshot, err := Take_snapshot(ctx, path, options, hash)
+	var partial *Partial_error
+	if errors.As(err, &partial) {
+		print_skipped(partial.Skipped)
+		if partial.Cause != nil {
+			fmt.Fprintf(os.Stderr, "⏱️ Stopped early (%v)\n", partial.Cause)
+		}
+		fmt.Fprintf(os.Stderr, "❌ Error: the scan of '%s' is incomplete; no snapshot saved or compared\n", path)
+		return false
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error: %v\n", err)
+		return false
+	}
+
+	if old != nil {
+		changes := Diff_snapshots(old, snapshot)
+		if jsonOutput {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if changes == nil {
+				changes = []Change{}
+			}
+			if err := encoder.Encode(changes); err != nil {
+				fmt.Fprintf(os.Stderr, "❌ Error: %v\n", err)
+				return false
+			}
+		} else {
+			fmt.Printf("🕒 Changes in '%s' since %s:\n", path, old.Taken.Local().Format(time.DateTime))
+			Print_changes(os.Stdout, changes, snapshot.Total()-old.Total(), human)
+		}
+	}
+	if snapshotFile != "" {
+		if err := Save_snapshot(snapshotFile, snapshot); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error: %v\n", err)
+			return false
+		}
+		fmt.Fprintf(os.Stderr, "💾 Saved a snapshot of %d entries in '%s' to '%s'\n", len(snapshot.Entries), path, snapshotFile)
+	}
+	return true
+}
+
+// string_list is a flag that may be given several times.
+type string_list []string
+
+func (l *string_list) String() string { return strings.Join(*l, ",") }
+
+func (l *string_list) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// build_filter turns the filter flags into a Filter, or nil when none
+// were given.
+func build_filter(include, exclude, includeRegex, excludeRegex []string, minSize, maxSize, newer, older string) (*Filter, error) {
+	filter := &Filter{Include: include, Exclude: exclude}
+	for _, expr := range includeRegex {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		filter.Include_regex = append(filter.Include_regex, re)
+	}
+	for _, expr := range excludeRegex {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		filter.Exclude_regex = append(filter.Exclude_regex, re)
+	}
+	var err error
+	if minSize != "" {
+		if filter.Min_size, err = Parse_size(minSize); err != nil {
+			return nil, err
+		}
+	}
+	if maxSize != "" {
+		if filter.Max_size, err = Parse_size(maxSize); err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now()
+	filter.Newer, filter.Older = newer, older
+	if newer != "" {
+		if filter.Modified_after, err = Parse_time(newer, now); err != nil {
+			return nil, err
+		}
+	}
+	if older != "" {
+		if filter.Modified_before, err = Parse_time(older, now); err != nil {
+			return nil, err
+		}
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	if len(exclude) == 0 && len(excludeRegex) == 0 && !filter.selects_files() {
+		return nil, nil
+	}
+	return filter, nil
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Snapshot records the files and directories of a tree at one moment, to
+// compare a later scan with. Paths are relative to Root and written with
+// forward slashes, so a snapshot taken on one machine can be compared with
+// the same tree elsewhere.
+//
+// Options records how the tree was measured, since two snapshots taken
+// with different options differ where the tree does not.
+type Snapshot struct {
+	Root    string           `json:"root"`
+	Taken   time.Time        `json:"taken"`
+	Hashed  bool             `json:"hashed"`
+	Options Snapshot_options `json:"options"`
+	Entries []Snapshot_entry `json:"entries"`
+}
+
+// Snapshot_options are the Walk_options that change what a snapshot
+// records, with the filter's regular expressions written out. Ages such as
+// -newer 7d are kept as given, since the time they stand for moves on
+// between two snapshots taken with the same flags.
+type Snapshot_options struct {
+	Allocated       bool     `json:"allocated,omitempty"`
+	Count_links     bool     `json:"count_links,omitempty"`
+	Follow_symlinks bool     `json:"follow_symlinks,omitempty"`
+	One_file_system bool     `json:"one_file_system,omitempty"`
+	Include         []string `json:"include,omitempty"`
+	Exclude         []string `json:"exclude,omitempty"`
+	Include_regex   []string `json:"include_regex,omitempty"`
+	Exclude_regex   []string `json:"exclude_regex,omitempty"`
+	Min_size        int64    `json:"min_size,omitempty"`
+	Max_size        int64    `json:"max_size,omitempty"`
+	Newer           string   `json:"newer,omitempty"`
+	Older           string   `json:"older,omitempty"`
+}
+
+// Snapshot_options_of returns the options of a snapshot taken with options.
+func Snapshot_options_of(options Walk_options) Snapshot_options {
+	recorded := Snapshot_options{
+		Allocated:       options.Allocated,
+		Count_links:     options.Count_links,
+		Follow_symlinks: options.Follow_symlinks,
+		One_file_system: options.One_file_system,
+	}
+	if filter := options.Filter; filter != nil {
+		recorded.Include = filter.Include
+		recorded.Exclude = filter.Exclude
+		for _, re := range filter.Include_regex {
+			recorded.Include_regex = append(recorded.Include_regex, re.String())
+		}
+		for _, re := range filter.Exclude_regex {
+			recorded.Exclude_regex = append(recorded.Exclude_regex, re.String())
+		}
+		recorded.Min_size = filter.Min_size
+		recorded.Max_size = filter.Max_size
+		recorded.Newer = time_limit(filter.Newer, filter.Modified_after)
+		recorded.Older = time_limit(filter.Older, filter.Modified_before)
+	}
+	return recorded
+}
+
+// Differences returns the names of the options that differ between o and
+// other, or nil when the snapshots can be compared.
+func (o Snapshot_options) Differences(other Snapshot_options) []string {
+	var names []string
+	differ := func(name string, same bool) {
+		if !same {
+			names = append(names, name)
+		}
+	}
+	differ("allocated", o.Allocated == other.Allocated)
+	differ("count links", o.Count_links == other.Count_links)
+	differ("follow symlinks", o.Follow_symlinks == other.Follow_symlinks)
+	differ("one file system", o.One_file_system == other.One_file_system)
+	differ("include", slices.Equal(o.Include, other.Include))
+	differ("exclude", slices.Equal(o.Exclude, other.Exclude))
+	differ("include regex", slices.Equal(o.Include_regex, other.Include_regex))
+	differ("exclude regex", slices.Equal(o.Exclude_regex, other.Exclude_regex))
+	differ("min size", o.Min_size == other.Min_size)
+	differ("max size", o.Max_size == other.Max_size)
+	differ("newer", o.Newer == other.Newer)
+	differ("older", o.Older == other.Older)
+	return names
+}
+
+// time_limit returns the flag text of a time limit, or the time itself
+// when it was set without one.
+func time_limit(text string, limit time.Time) string {
+	if text != "" || limit.IsZero() {
+		return text
+	}
+	return limit.UTC().Format(time.RFC3339Nano)
+}
+
+// Snapshot_entry is one file or directory in a Snapshot. The size of a
+// directory is the total below it; Modified and Sha256 are for files only.
+type Snapshot_entry struct {
+	Path     string    `json:"path"`
+	Is_dir   bool      `json:"is_dir,omitempty"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"mtime,omitzero"`
+	Sha256   string    `json:"sha256,omitempty"`
+}
+
+// Take_snapshot walks root and records every file and directory counted,
+// with the SHA-256 of each file when hash is true.
+//
+// Parameters:
+//   - ctx: Stops the walk when done.
+//   - root: The directory to record.
+//   - options: How to walk it; On_file is used by Take_snapshot.
+//   - hash: Whether to read every file to record its SHA-256, which finds
+//     files changed without changing size, at the cost of reading them.
+//
+// Returns:
+//   - *Snapshot: The entries sorted by path.
+//   - error: As for Walk_tree_context; a *Partial_error comes with a
+//     snapshot of what could be read, which is best not saved.
+//
+// Example:
+//
+//	before, _ := Take_snapshot(ctx, `C:\oracle`, Walk_options{}, false)
+//	// ... run the installer ...
+//	after, _ := Take_snapshot(ctx, `C:\oracle`, Walk_options{}, false)
+//	for _, change := range Diff_snapshots(before, after) {
+//	    fmt.Println(change.Kind, change.Path, change.Delta)
+//	}
+func Take_snapshot(ctx context.Context, root string, options Walk_options, hash bool) (*Snapshot, error) {
+	snapshot := &Snapshot{Root: root, Taken: time.Now().UTC(), Hashed: hash, Options: Snapshot_options_of(options)}
+	var mu sync.Mutex
+	var hashErrors []Skipped_entry
+	options.On_file = func(filePath string, info os.FileInfo, stat File_stat) {
+		entry := Snapshot_entry{Path: relative_slash(root, filePath), Size: info.Size(), Modified: info.ModTime().UTC()}
+		if options.Allocated {
+			entry.Size = stat.Allocated
+		}
+		var hashErr error
+		if hash && info.Mode().IsRegular() {
+			entry.Sha256, hashErr = hash_file(filePath, -1)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if hashErr != nil {
+			hashErrors = append(hashErrors, Skipped_entry{Path: filePath, Error: hashErr.Error(), Err: hashErr})
+		}
+		snapshot.Entries = append(snapshot.Entries, entry)
+	}
+
+	tree, err := Walk_tree_context(ctx, root, options)
+	if tree == nil {
+		return nil, err
+	}
+	var addDirs func(node *Size_node)
+	addDirs = func(node *Size_node) {
+		if node.Is_dir && node != tree {
+			snapshot.Entries = append(snapshot.Entries, Snapshot_entry{Path: relative_slash(root, node.Path), Is_dir: true, Size: node.Size})
+		}
+		for _, child := range node.Children {
+			addDirs(child)
+		}
+	}
+	addDirs(tree)
+	sort.Slice(snapshot.Entries, func(i, j int) bool { return snapshot.Entries[i].Path < snapshot.Entries[j].Path })
+
+	if len(hashErrors) > 0 {
+		partial, ok := err.(*Partial_error)
+		if !ok {
+			if err != nil {
+				return nil, err
+			}
+			partial = &Partial_error{}
+		}
+		partial.Skipped = append(partial.Skipped, hashErrors...)
+		err = partial
+	}
+	return snapshot, err
+}
+
+// relative_slash returns path relative to root, with forward slashes.
+func relative_slash(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Save_snapshot writes snapshot to a JSON file.
+func Save_snapshot(filename string, snapshot *Snapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0o644)
+}
+
+// Load_snapshot reads a snapshot written by Save_snapshot.
+func Load_snapshot(filename string) (*Snapshot, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("%s: not a snapshot: %w", filename, err)
+	}
+	return &snapshot, nil
+}
+
+// Change kinds reported by Diff_snapshots.
+const (
+	Change_added   = "added"
+	Change_removed = "removed"
+	Change_grown   = "grown"
+	Change_shrunk  = "shrunk"
+	Change_changed = "changed" // same size, different modification time or contents
+)
+
+// Change is one difference between two snapshots.
+type Change struct {
+	Path     string `json:"path"`
+	Is_dir   bool   `json:"is_dir,omitempty"`
+	Kind     string `json:"kind"`
+	Old_size int64  `json:"old_size"`
+	New_size int64  `json:"new_size"`
+	Delta    int64  `json:"delta"`
+}
+
+// Diff_snapshots returns what changed from old to new, sorted by path. A
+// directory added or removed as a whole is reported once, without the
+// entries inside it. A file of the same size counts as changed when its
+// hash differs, if both snapshots have one, or else its modification time.
+func Diff_snapshots(old, new *Snapshot) []Change {
+	oldEntries := map[string]Snapshot_entry{}
+	for _, entry := range old.Entries {
+		oldEntries[entry.Path] = entry
+	}
+	newEntries := map[string]Snapshot_entry{}
+	for _, entry := range new.Entries {
+		newEntries[entry.Path] = entry
+	}
+
+	var changes []Change
+	for _, entry := range new.Entries {
+		before, ok := oldEntries[entry.Path]
+		switch {
+		case !ok:
+			if !inside_any(entry.Path, oldEntries, newEntries) {
+				changes = append(changes, Change{Path: entry.Path, Is_dir: entry.Is_dir, Kind: Change_added, New_size: entry.Size, Delta: entry.Size})
+			}
+		case entry.Size > before.Size:
+			changes = append(changes, Change{Path: entry.Path, Is_dir: entry.Is_dir, Kind: Change_grown, Old_size: before.Size, New_size: entry.Size, Delta: entry.Size - before.Size})
+		case entry.Size < before.Size:
+			changes = append(changes, Change{Path: entry.Path, Is_dir: entry.Is_dir, Kind: Change_shrunk, Old_size: before.Size, New_size: entry.Size, Delta: entry.Size - before.Size})
+		case !entry.Is_dir && changed_file(before, entry):
+			changes = append(changes, Change{Path: entry.Path, Kind: Change_changed, Old_size: before.Size, New_size: entry.Size})
+		}
+	}
+	for _, entry := range old.Entries {
+		if _, ok := newEntries[entry.Path]; !ok && !inside_any(entry.Path, newEntries, oldEntries) {
+			changes = append(changes, Change{Path: entry.Path, Is_dir: entry.Is_dir, Kind: Change_removed, Old_size: entry.Size, Delta: -entry.Size})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// inside_any reports whether p lies in a directory that is in this but
+// missing from other, that is one added or removed as a whole.
+func inside_any(p string, other, this map[string]Snapshot_entry) bool {
+	for dir := path.Dir(p); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if _, ok := other[dir]; ok {
+			return false
+		}
+		if entry, ok := this[dir]; ok && entry.Is_dir {
+			return true
+		}
+	}
+	return false
+}
+
+func changed_file(before, after Snapshot_entry) bool {
+	if before.Sha256 != "" && after.Sha256 != "" {
+		return before.Sha256 != after.Sha256
+	}
+	return !before.Modified.Equal(after.Modified)
+}
+
+// Total returns the size of all the files in the snapshot.
+func (s *Snapshot) Total() int64 {
+	var total int64
+	for _, entry := range s.Entries {
+		if !entry.Is_dir {
+			total += entry.Size
+		}
+	}
+	return total
+}
+
+// Print_changes writes one line per change, then the number of changes
+// and delta, the growth of the tree as a whole.
+func Print_changes(out io.Writer, changes []Change, delta int64, human bool) {
+	size := func(n int64) string {
+		if human {
+			return Format_size(n, true)
+		}
+		return fmt.Sprintf("%d bytes", n)
+	}
+	for _, change := range changes {
+		name := change.Path
+		if change.Is_dir {
+			name += "/"
+		}
+		switch change.Kind {
+		case Change_added:
+			fmt.Fprintf(out, "➕ added    %s  %s\n", size(change.New_size), name)
+		case Change_removed:
+			fmt.Fprintf(out, "➖ removed  %s  %s\n", size(change.Old_size), name)
+		case Change_grown:
+			fmt.Fprintf(out, "📈 grown    +%s (%s → %s)  %s\n", size(change.Delta), size(change.Old_size), size(change.New_size), name)
+		case Change_shrunk:
+			fmt.Fprintf(out, "📉 shrunk   -%s (%s → %s)  %s\n", size(-change.Delta), size(change.Old_size), size(change.New_size), name)
+		case Change_changed:
+			fmt.Fprintf(out, "✏️ changed  %s  %s\n", size(change.New_size), name)
+		}
+	}
+	sign := "+"
+	if delta < 0 {
+		sign, delta = "-", -delta
+	}
+	fmt.Fprintf(out, "📦 %d changes, %s%s in total\n", len(changes), sign, size(delta))
+}
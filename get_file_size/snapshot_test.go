@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestDiff_snapshots(t *testing.T) {
+	then := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	later := then.Add(time.Hour)
+	old := &Snapshot{Entries: []Snapshot_entry{
+		{Path: "app", Is_dir: true, Size: 300},
+		{Path: "app/grows.log", Size: 100, Modified: then},
+		{Path: "app/shrinks.db", Size: 100, Modified: then},
+		{Path: "app/same.txt", Size: 100, Modified: then},
+		{Path: "cache", Is_dir: true, Size: 50},
+		{Path: "cache/a.bin", Size: 50, Modified: then},
+		{Path: "touched.txt", Size: 10, Modified: then},
+		{Path: "hashed.txt", Size: 10, Modified: then, Sha256: "aa"},
+		{Path: "gone.txt", Size: 7, Modified: then},
+	}}
+	new := &Snapshot{Entries: []Snapshot_entry{
+		{Path: "app", Is_dir: true, Size: 330},
+		{Path: "app/grows.log", Size: 150, Modified: later},
+		{Path: "app/shrinks.db", Size: 80, Modified: later},
+		{Path: "app/same.txt", Size: 100, Modified: then},
+		{Path: "plugins", Is_dir: true, Size: 40},
+		{Path: "plugins/one", Is_dir: true, Size: 40},
+		{Path: "plugins/one/p.dll", Size: 40, Modified: later},
+		{Path: "touched.txt", Size: 10, Modified: later},
+		{Path: "hashed.txt", Size: 10, Modified: later, Sha256: "aa"},
+		{Path: "new.txt", Size: 3, Modified: later},
+	}}
+
+	want := []Change{
+		{Path: "app", Is_dir: true, Kind: Change_grown, Old_size: 300, New_size: 330, Delta: 30},
+		{Path: "app/grows.log", Kind: Change_grown, Old_size: 100, New_size: 150, Delta: 50},
+		{Path: "app/shrinks.db", Kind: Change_shrunk, Old_size: 100, New_size: 80, Delta: -20},
+		{Path: "cache", Is_dir: true, Kind: Change_removed, Old_size: 50, Delta: -50},
+		{Path: "gone.txt", Kind: Change_removed, Old_size: 7, Delta: -7},
+		{Path: "new.txt", Kind: Change_added, New_size: 3, Delta: 3},
+		{Path: "plugins", Is_dir: true, Kind: Change_added, New_size: 40, Delta: 40},
+		{Path: "touched.txt", Kind: Change_changed, Old_size: 10, New_size: 10},
+	}
+	if got := Diff_snapshots(old, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff_snapshots:\ngot  %+v\nwant %+v", got, want)
+	}
+	if got := Diff_snapshots(old, old); len(got) != 0 {
+		t.Errorf("Diff_snapshots of a snapshot with itself = %+v, want none", got)
+	}
+	if got, want := new.Total()-old.Total(), int64(393-377); got != want {
+		t.Errorf("growth = %d, want %d", got, want)
+	}
+}
+
+func TestSnapshot_options_differences(t *testing.T) {
+	base := Walk_options{Filter: &Filter{Exclude: []string{".git"}}}
+	same := Walk_options{Workers: 8, Keep_going: true, Filter: &Filter{Exclude: []string{".git"}}}
+	if got := Snapshot_options_of(base).Differences(Snapshot_options_of(same)); got != nil {
+		t.Errorf("options that do not change sizes differ: %v", got)
+	}
+
+	other := Walk_options{
+		Allocated: true,
+		Filter:    &Filter{Exclude: []string{".git"}, Include_regex: []*regexp.Regexp{regexp.MustCompile(`\.go$`)}},
+	}
+	got := Snapshot_options_of(base).Differences(Snapshot_options_of(other))
+	if want := []string{"allocated", "include regex"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Differences = %v, want %v", got, want)
+	}
+}
+
+func TestSnapshot_age_filter(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "recent.txt"), []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	saved := filepath.Join(t.TempDir(), "snapshot.json")
+
+	// Each run resolves -newer 7d against its own clock.
+	for _, diff := range []string{"", saved} {
+		filter, err := build_filter(nil, nil, nil, nil, "", "", "7d", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if !run_snapshot(context.Background(), root, Walk_options{Filter: filter}, saved, diff, false, false, true) {
+			t.Fatalf("run_snapshot with -newer 7d and diff %q failed", diff)
+		}
+	}
+
+	filter, err := build_filter(nil, nil, nil, nil, "", "", "14d", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run_snapshot(context.Background(), root, Walk_options{Filter: filter}, "", saved, false, false, true) {
+		t.Errorf("run_snapshot compared a -newer 7d snapshot with -newer 14d")
+	}
+}
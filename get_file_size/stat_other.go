@@ -0,0 +1,23 @@
+//go:build !unix && !windows
+
+package main
+
+import "os"
+
+// Stat_of returns what the walker needs to know about a file beyond
+// os.FileInfo. Systems other than Unix and Windows say nothing about
+// blocks or file identity, so the allocated size is the apparent size and
+// hard links cannot be recognised.
+func Stat_of(path string, info os.FileInfo) File_stat {
+	return File_stat{Allocated: info.Size()}
+}
+
+// Owner_of returns "", since these systems do not say who owns a file.
+func Owner_of(path string, info os.FileInfo) string {
+	return ""
+}
+
+// Owner_id returns "", as Owner_of does.
+func Owner_id(path string, info os.FileInfo) string {
+	return ""
+}
@@ -0,0 +1,63 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// Stat_of returns what the walker needs to know about a file beyond
+// os.FileInfo, from the stat data the system already returned.
+//
+// Parameters:
+//   - path: The path info was read from (unused on Unix).
+//   - info: The result of os.Lstat or os.Stat on path.
+//
+// Returns:
+//   - File_stat: Allocated is the number of 512-byte blocks times 512, as
+//     du counts them; Device and Inode identify the file.
+func Stat_of(path string, info os.FileInfo) File_stat {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return File_stat{Allocated: info.Size()}
+	}
+	return File_stat{
+		Allocated: int64(st.Blocks) * 512,
+		Device:    uint64(st.Dev),
+		Inode:     uint64(st.Ino),
+		Links:     uint64(st.Nlink),
+		Has_id:    true,
+	}
+}
+
+// Owner_of returns the name of the user who owns the file, or the user ID
+// when it has no name.
+func Owner_of(path string, info os.FileInfo) string {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	uid := strconv.FormatUint(uint64(st.Uid), 10)
+	if name, ok := owner_names.Load(uid); ok {
+		return name.(string)
+	}
+	name := uid
+	if u, err := user.LookupId(uid); err == nil {
+		name = u.Username
+	}
+	owner_names.Store(uid, name)
+	return name
+}
+
+// Owner_id returns the user and group IDs of the file as "uid:gid", for
+// telling whether two files have the same owner.
+func Owner_id(path string, info os.FileInfo) string {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	return strconv.FormatUint(uint64(st.Uid), 10) + ":" + strconv.FormatUint(uint64(st.Gid), 10)
+}
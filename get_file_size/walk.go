@@ -0,0 +1,444 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Size_node is one directory (or the root file) in a size tree.
+// Size is the total of every file below the node, Files and Dirs
+// count the files and subdirectories below it at any depth.
+// Size is the allocated size when the tree was walked with
+// Walk_options.Allocated; Apparent_size is always the total length.
+// Stats is only filled in on the root, when asked for.
+type Size_node struct {
+	Path          string       `json:"path"`
+	Name          string       `json:"name"`
+	Is_dir        bool         `json:"is_dir"`
+	Size          int64        `json:"size"`
+	Apparent_size int64        `json:"apparent_size"`
+	Files         int64        `json:"files"`
+	Dirs          int64        `json:"dirs"`
+	Children      []*Size_node `json:"children,omitempty"`
+	Stats         *Walk_stats  `json:"stats,omitempty"`
+
+	ownSize     int64 // files directly in this directory
+	ownApparent int64
+	ownFiles    int64
+}
+
+// File_stat is what the walker needs to know about a file beyond
+// os.FileInfo; Stat_of fills it in for each system.
+type File_stat struct {
+	Allocated int64  // bytes of disk the file takes up
+	Device    uint64 // the file system the file is on
+	Inode     uint64 // the file within its file system
+	Links     uint64 // hard links to the file
+	Has_id    bool   // whether Device and Inode are known
+}
+
+// file_key identifies a file across hard links and symbolic links.
+type file_key struct {
+	device, inode uint64
+}
+
+// Walk_options controls Walk_tree.
+type Walk_options struct {
+	// Workers is the number of directories read at the same time.
+	// Zero means four per CPU, since the work is mostly waiting on the disk.
+	Workers int
+
+	// Allocated measures the disk space taken up (whole blocks, less for
+	// sparse files) rather than the length of the files, and counts the
+	// blocks of the directories themselves too, as du does.
+	Allocated bool
+
+	// Count_links counts a file once for every hard link to it. By
+	// default, as with du, a file is counted the first time it is met.
+	Count_links bool
+
+	// Follow_symlinks measures what symbolic links point to (du -L).
+	// By default a link counts as itself and is not followed.
+	Follow_symlinks bool
+
+	// One_file_system skips directories on a different file system from
+	// path (du -x).
+	One_file_system bool
+
+	// Keep_going skips entries that cannot be read instead of giving up
+	// on the whole walk; they are listed in the *Partial_error returned
+	// with the tree.
+	Keep_going bool
+
+	// Filter chooses the files counted and the directories entered;
+	// nil counts everything.
+	Filter *Filter
+
+	// Extension_stats and Owner_stats fill in the Stats of the root with
+	// the files counted grouped by extension and by owner.
+	Extension_stats bool
+	Owner_stats     bool
+
+	// On_file, if set, is called for every file counted. It is called
+	// from several goroutines at once.
+	On_file func(path string, info os.FileInfo, stat File_stat)
+}
+
+// Skipped_entry is a path the walk could not read, and why.
+type Skipped_entry struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+	Err   error  `json:"-"`
+}
+
+// Partial_error is returned together with a tree that is missing
+// something: the entries a Keep_going walk skipped, or everything not yet
+// read when the context was done. Cause is the context's error in that
+// case, and nil otherwise.
+type Partial_error struct {
+	Skipped []Skipped_entry
+	Cause   error
+}
+
+func (e *Partial_error) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("walk stopped early (%v), %d entries skipped", e.Cause, len(e.Skipped))
+	}
+	return fmt.Sprintf("%d entries skipped", len(e.Skipped))
+}
+
+func (e *Partial_error) Unwrap() error { return e.Cause }
+
+// Walk_tree returns the size tree of path. Directories are read in
+// parallel by a bounded pool of workers; a directory that finds every
+// worker busy reads its subdirectories itself, so the walk never blocks.
+//
+// Parameters:
+//   - path: The file or directory to measure.
+//   - options: How to walk; the zero value uses the defaults.
+//
+// Returns:
+//   - *Size_node: The root of the tree, with the totals filled in.
+//   - error: The first error met while reading the tree, or with
+//     Keep_going a *Partial_error listing the entries left out of it.
+//
+// Example:
+//
+//	root, err := Walk_tree(`C:\downloads`, Walk_options{Allocated: true})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	fmt.Println(Format_size(root.Size, true))
+func Walk_tree(path string, options Walk_options) (*Size_node, error) {
+	return Walk_tree_context(context.Background(), path, options)
+}
+
+// Walk_tree_context is Walk_tree with a context to stop the walk, for a
+// timeout or an interrupt on a huge tree. When ctx is done the walk stops
+// reading and returns what it has counted so far with a *Partial_error.
+//
+// Returns:
+//   - *Size_node: The root of the tree; nil only when path itself cannot
+//     be read, or an entry cannot be read without Keep_going.
+//   - error: nil, the error that stopped a strict walk, or a
+//     *Partial_error alongside a partial tree.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+//	defer cancel()
+//	root, err := Walk_tree_context(ctx, "/", Walk_options{Keep_going: true})
+//	var partial *Partial_error
+//	if errors.As(err, &partial) {
+//	    for _, skipped := range partial.Skipped {
+//	        fmt.Println("skipped", skipped.Path, skipped.Error)
+//	    }
+//	} else if err != nil {
+//	    log.Fatal(err)
+//	}
+//	fmt.Println(Format_size(root.Size, true), "at least")
+func Walk_tree_context(ctx context.Context, path string, options Walk_options) (*Size_node, error) {
+	stat := os.Lstat
+	if options.Follow_symlinks {
+		stat = os.Stat
+	}
+	info, err := stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := options.Workers
+	if workers <= 0 {
+		workers = 4 * runtime.NumCPU()
+	}
+	w := &walker{
+		ctx:     ctx,
+		root:    path,
+		options: options,
+		slots:   make(chan struct{}, workers),
+		seen:    map[file_key]bool{},
+	}
+	rootStat := Stat_of(path, info)
+	w.device = rootStat.Device
+
+	root := &Size_node{Path: path, Name: filepath.Base(path), Is_dir: info.IsDir()}
+	if options.Extension_stats {
+		w.extensions = group_counter{}
+	}
+	if options.Owner_stats {
+		w.owners = group_counter{}
+	}
+	if !info.IsDir() {
+		if options.Filter.Selected(root.Name, info) {
+			w.count(root, path, info, rootStat)
+		}
+		sum_tree(root)
+		root.Stats = w.stats()
+		return root, nil
+	}
+	if options.Allocated && !options.Filter.selects_files() {
+		root.ownSize = rootStat.Allocated
+	}
+	w.first(rootStat, true)
+	w.wg.Add(1)
+	w.walk(root)
+	w.wg.Wait()
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	sum_tree(root)
+	root.Stats = w.stats()
+	if len(w.skipped) > 0 || ctx.Err() != nil {
+		sort.Slice(w.skipped, func(i, j int) bool { return w.skipped[i].Path < w.skipped[j].Path })
+		return root, &Partial_error{Skipped: w.skipped, Cause: ctx.Err()}
+	}
+	return root, nil
+}
+
+// walker holds the state shared by the goroutines of one walk.
+type walker struct {
+	ctx     context.Context
+	root    string
+	options Walk_options
+	device  uint64        // the file system of the root
+	slots   chan struct{} // one per worker that may run
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	err     error
+	skipped []Skipped_entry
+	seen    map[file_key]bool // files with several links, and followed directories
+
+	extensions group_counter // nil unless Extension_stats
+	owners     group_counter // nil unless Owner_stats
+}
+
+// fail records that path could not be read. A strict walk stops at the
+// first error; a Keep_going walk lists the path and carries on.
+func (w *walker) fail(path string, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.options.Keep_going {
+		w.skipped = append(w.skipped, Skipped_entry{Path: path, Error: err.Error(), Err: err})
+	} else if w.err == nil {
+		w.err = err
+	}
+}
+
+// stopped reports whether the walk should read no further.
+func (w *walker) stopped() bool {
+	if w.ctx.Err() != nil {
+		return true
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err != nil
+}
+
+// first reports whether the file of stat is met for the first time. Files
+// without an identity, and files that cannot have been met before, always
+// are.
+func (w *walker) first(stat File_stat, isDir bool) bool {
+	if !stat.Has_id {
+		return true
+	}
+	switch {
+	case isDir && !w.options.Follow_symlinks:
+		return true // only a followed link can lead back to a directory
+	case !isDir && w.options.Count_links:
+		return true
+	case !isDir && stat.Links < 2 && !w.options.Follow_symlinks:
+		return true // nothing else leads to it
+	}
+	key := file_key{stat.Device, stat.Inode}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.seen[key] {
+		return false
+	}
+	w.seen[key] = true
+	return true
+}
+
+// count counts a file directly in node, and in the statistics of the
+// walk.
+func (w *walker) count(node *Size_node, path string, info os.FileInfo, stat File_stat) {
+	size := info.Size()
+	if w.options.Allocated {
+		size = stat.Allocated
+	}
+	node.ownSize += size
+	node.ownApparent += info.Size()
+	node.ownFiles++
+	if w.options.On_file != nil {
+		w.options.On_file(path, info, stat)
+	}
+
+	if w.extensions == nil && w.owners == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.extensions != nil {
+		w.extensions.add(Extension_of(info.Name()), 1, size)
+	}
+	if w.owners != nil {
+		w.owners.add(Owner_of(path, info), 1, size)
+	}
+}
+
+// stats returns the statistics of the walk, or nil if none were asked for.
+func (w *walker) stats() *Walk_stats {
+	if w.extensions == nil && w.owners == nil {
+		return nil
+	}
+	return &Walk_stats{By_extension: w.extensions.sorted(), By_owner: w.owners.sorted()}
+}
+
+// walk reads the directory of node and starts walking its subdirectories.
+// Only this goroutine touches node until the walk is over.
+func (w *walker) walk(node *Size_node) {
+	defer w.wg.Done()
+	if w.stopped() {
+		return
+	}
+
+	// ReadDir returns the entries it read before an error, which a
+	// Keep_going walk still counts.
+	entries, err := os.ReadDir(node.Path)
+	if err != nil {
+		w.fail(node.Path, err)
+		if !w.options.Keep_going {
+			return
+		}
+	}
+	for _, entry := range entries {
+		if w.ctx.Err() != nil {
+			return
+		}
+		childPath := filepath.Join(node.Path, entry.Name())
+		rel, _ := filepath.Rel(w.root, childPath)
+		if w.options.Filter.Excluded(rel) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			w.fail(childPath, err)
+			if !w.options.Keep_going {
+				return
+			}
+			continue
+		}
+		if info.Mode()&os.ModeSymlink != 0 && w.options.Follow_symlinks {
+			if target, err := os.Stat(childPath); err == nil {
+				info = target
+			} // a dangling link counts as itself
+		}
+		stat := Stat_of(childPath, info)
+
+		if info.IsDir() {
+			if w.options.One_file_system && stat.Has_id && stat.Device != w.device {
+				continue
+			}
+			if !w.first(stat, true) {
+				continue
+			}
+			child := &Size_node{Path: childPath, Name: entry.Name(), Is_dir: true}
+			if w.options.Allocated && !w.options.Filter.selects_files() {
+				child.ownSize = stat.Allocated
+			}
+			node.Children = append(node.Children, child)
+			continue
+		}
+		if w.options.Filter.Selected(rel, info) && w.first(stat, false) {
+			w.count(node, childPath, info, stat)
+		}
+	}
+
+	for _, child := range node.Children {
+		w.wg.Add(1)
+		select {
+		case w.slots <- struct{}{}:
+			go func(child *Size_node) {
+				defer func() { <-w.slots }()
+				w.walk(child)
+			}(child)
+		default:
+			w.walk(child)
+		}
+	}
+}
+
+// sum_tree fills in the totals of node and everything below it.
+func sum_tree(node *Size_node) {
+	node.Size = node.ownSize
+	node.Apparent_size = node.ownApparent
+	node.Files = node.ownFiles
+	for _, child := range node.Children {
+		sum_tree(child)
+		node.Size += child.Size
+		node.Apparent_size += child.Apparent_size
+		node.Files += child.Files
+		node.Dirs += child.Dirs + 1
+	}
+}
+
+// Sort_tree orders the children of every node, largest first when by is
+// "size", or alphabetically when by is "name".
+func Sort_tree(node *Size_node, by string) {
+	switch by {
+	case "size":
+		sort.SliceStable(node.Children, func(i, j int) bool {
+			if node.Children[i].Size != node.Children[j].Size {
+				return node.Children[i].Size > node.Children[j].Size
+			}
+			return node.Children[i].Name < node.Children[j].Name
+		})
+	case "name":
+		sort.SliceStable(node.Children, func(i, j int) bool { return node.Children[i].Name < node.Children[j].Name })
+	}
+	for _, child := range node.Children {
+		Sort_tree(child, by)
+	}
+}
+
+// Prune_tree drops the nodes deeper than depth below node; depth 0 keeps
+// only node itself. A negative depth keeps everything.
+func Prune_tree(node *Size_node, depth int) {
+	if depth < 0 {
+		return
+	}
+	if depth == 0 {
+		node.Children = nil
+		return
+	}
+	for _, child := range node.Children {
+		Prune_tree(child, depth-1)
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// make_walk_tree writes a tree with a file of 10000 bytes under two names,
+// a symbolic link to it, a symbolic link to its directory, one back up to
+// the root, and a small file:
+//
+//	a/data.bin, a/hard.bin     the same file
+//	b/link.bin -> ../a/data.bin
+//	b/dir -> ../a
+//	b/up -> ..
+//	c/small.txt                5 bytes
+//
+// It returns the root of the tree.
+func make_walk_tree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, dir := range []string{"a", "b", "c"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "a/data.bin"), bytes.Repeat([]byte("x"), 10000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "c/small.txt"), []byte("small"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(root, "a/data.bin"), filepath.Join(root, "a/hard.bin")); err != nil {
+		t.Skipf("hard links are not supported here: %v", err)
+	}
+	links := map[string]string{
+		"b/link.bin": filepath.Join("..", "a", "data.bin"),
+		"b/dir":      filepath.Join("..", "a"),
+		"b/up":       "..",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skipf("symbolic links are not supported here: %v", err)
+		}
+	}
+	return root
+}
+
+// lstat_size returns the length of name in root, a symbolic link counting
+// as itself.
+func lstat_size(t *testing.T, root, name string) int64 {
+	t.Helper()
+	info, err := os.Lstat(filepath.Join(root, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestWalk_tree_links(t *testing.T) {
+	root := make_walk_tree(t)
+	symlinks := lstat_size(t, root, "b/link.bin") + lstat_size(t, root, "b/dir") + lstat_size(t, root, "b/up")
+	tests := []struct {
+		name    string
+		options Walk_options
+		size    int64
+		files   int64
+		dirs    int64
+	}{
+		// The second name of data.bin is not counted, and the links count
+		// as themselves.
+		{"default", Walk_options{}, 10000 + 5 + symlinks, 5, 3},
+		{"count links", Walk_options{Count_links: true}, 2*10000 + 5 + symlinks, 6, 3},
+		// link.bin and b/dir lead to what a and data.bin already count,
+		// and b/up to the root.
+		{"follow symlinks", Walk_options{Follow_symlinks: true}, 10000 + 5, 2, 3},
+	}
+	for _, tt := range tests {
+		node, err := Walk_tree(root, tt.options)
+		if err != nil {
+			t.Fatalf("Walk_tree (%s): %v", tt.name, err)
+		}
+		if node.Size != tt.size || node.Apparent_size != tt.size || node.Files != tt.files || node.Dirs != tt.dirs {
+			t.Errorf("Walk_tree (%s) = size %d, apparent %d, %d files, %d dirs; want size %d, %d files, %d dirs",
+				tt.name, node.Size, node.Apparent_size, node.Files, node.Dirs, tt.size, tt.files, tt.dirs)
+		}
+	}
+}
+
+func TestWalk_tree_allocated(t *testing.T) {
+	root := make_walk_tree(t)
+	// As du counts: the blocks of the directories and of each file once.
+	var want int64
+	for _, name := range []string{".", "a", "b", "c", "a/data.bin", "b/link.bin", "b/dir", "b/up", "c/small.txt"} {
+		info, err := os.Lstat(filepath.Join(root, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want += Stat_of(filepath.Join(root, name), info).Allocated
+	}
+	node, err := Walk_tree(root, Walk_options{Allocated: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Size != want {
+		t.Errorf("allocated size = %d, want %d", node.Size, want)
+	}
+	if apparent := 10000 + 5 + lstat_size(t, root, "b/link.bin") + lstat_size(t, root, "b/dir") + lstat_size(t, root, "b/up"); node.Apparent_size != apparent {
+		t.Errorf("apparent size with -allocated = %d, want %d", node.Apparent_size, apparent)
+	}
+}
+
+func TestWalk_tree_sparse(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "sparse.bin")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One byte at the end of 64 MiB leaves a hole wherever holes exist.
+	if _, err := file.WriteAt([]byte{1}, 64<<20-1); err != nil {
+		file.Close()
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Stat_of(path, info).Allocated >= info.Size() {
+		t.Skip("this file system does not make sparse files")
+	}
+
+	node, err := Walk_tree(path, Walk_options{Allocated: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Apparent_size != 64<<20 || node.Size >= node.Apparent_size {
+		t.Errorf("sparse file: size %d, apparent %d; want less than %d allocated", node.Size, node.Apparent_size, 64<<20)
+	}
+}
+
+func TestWalk_tree_one_file_system(t *testing.T) {
+	// A directory on another file system, reached by a symbolic link.
+	other, err := os.MkdirTemp("/dev/shm", "get_file_size_test")
+	if err != nil {
+		t.Skipf("no other file system to test with: %v", err)
+	}
+	defer os.RemoveAll(other)
+	root := t.TempDir()
+	rootInfo, err := os.Stat(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherInfo, err := os.Stat(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Stat_of(root, rootInfo).Device == Stat_of(other, otherInfo).Device {
+		t.Skip("/dev/shm is on the same file system as the temporary directory")
+	}
+	if err := os.WriteFile(filepath.Join(other, "away.txt"), []byte("away"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "here.txt"), []byte("here!"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(other, filepath.Join(root, "mount")); err != nil {
+		t.Skipf("symbolic links are not supported here: %v", err)
+	}
+
+	for _, tt := range []struct {
+		oneFileSystem bool
+		size, dirs    int64
+	}{
+		{false, 5 + 4, 1},
+		{true, 5, 0},
+	} {
+		node, err := Walk_tree(root, Walk_options{Follow_symlinks: true, One_file_system: tt.oneFileSystem})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if node.Size != tt.size || node.Dirs != tt.dirs {
+			t.Errorf("Walk_tree (one file system %v) = size %d, %d dirs; want size %d, %d dirs",
+				tt.oneFileSystem, node.Size, node.Dirs, tt.size, tt.dirs)
+		}
+	}
+}
+
+// write_files writes each file of files, by slash-separated path below
+// root, with the given number of bytes.
+func write_files(t *testing.T, root string, files map[string]int) {
+	t.Helper()
+	for name, size := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, bytes.Repeat([]byte("x"), size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// names returns the names of the children of node, in order.
+func names(node *Size_node) []string {
+	var result []string
+	for _, child := range node.Children {
+		result = append(result, child.Name)
+	}
+	return result
+}
+
+func TestWalk_tree_totals(t *testing.T) {
+	root := t.TempDir()
+	write_files(t, root, map[string]int{
+		"top.txt":          1,
+		"tiny/a.txt":       10,
+		"large/b.bin":      300,
+		"large/deep/c.bin": 200,
+		"middle/d.txt":     100,
+		"middle/e.txt":     100,
+		"a/f.txt":          0,
+	})
+	// One worker reads most directories itself, many read them all at once.
+	for _, workers := range []int{1, 64} {
+		node, err := Walk_tree(root, Walk_options{Workers: workers})
+		if err != nil {
+			t.Fatalf("Walk_tree (%d workers): %v", workers, err)
+		}
+		if node.Size != 711 || node.Files != 7 || node.Dirs != 5 || !node.Is_dir {
+			t.Errorf("root (%d workers) = size %d, %d files, %d dirs; want 711, 7, 5", workers, node.Size, node.Files, node.Dirs)
+		}
+
+		Sort_tree(node, "size")
+		if got, want := names(node), []string{"large", "middle", "tiny", "a"}; !slices.Equal(got, want) {
+			t.Errorf("sorted by size (%d workers) = %v, want %v", workers, got, want)
+		}
+		large := node.Children[0]
+		if large.Size != 500 || large.Files != 2 || large.Dirs != 1 || large.Path != filepath.Join(root, "large") {
+			t.Errorf("large (%d workers) = %s, size %d, %d files, %d dirs; want size 500, 2 files, 1 dir",
+				workers, large.Path, large.Size, large.Files, large.Dirs)
+		}
+		Sort_tree(node, "name")
+		if got, want := names(node), []string{"a", "large", "middle", "tiny"}; !slices.Equal(got, want) {
+			t.Errorf("sorted by name (%d workers) = %v, want %v", workers, got, want)
+		}
+	}
+}
+
+func TestSort_tree_ties(t *testing.T) {
+	node := &Size_node{Children: []*Size_node{
+		{Name: "b", Size: 5}, {Name: "c", Size: 9}, {Name: "a", Size: 5},
+	}}
+	Sort_tree(node, "size")
+	if got, want := names(node), []string{"c", "a", "b"}; !slices.Equal(got, want) {
+		t.Errorf("sorted by size = %v, want %v (equal sizes by name)", got, want)
+	}
+}
+
+func TestPrune_tree(t *testing.T) {
+	tree := func() *Size_node {
+		return &Size_node{Name: "root", Children: []*Size_node{
+			{Name: "a", Children: []*Size_node{{Name: "a1", Children: []*Size_node{{Name: "a11"}}}}},
+			{Name: "b"},
+		}}
+	}
+	// depth counts how many levels are left below each node.
+	var depth func(node *Size_node) int
+	depth = func(node *Size_node) int {
+		deepest := 0
+		for _, child := range node.Children {
+			deepest = max(deepest, depth(child)+1)
+		}
+		return deepest
+	}
+	for _, tt := range []struct{ prune, want int }{{0, 0}, {1, 1}, {2, 2}, {5, 3}, {-1, 3}} {
+		node := tree()
+		Prune_tree(node, tt.prune)
+		if got := depth(node); got != tt.want {
+			t.Errorf("Prune_tree(%d) left %d levels, want %d", tt.prune, got, tt.want)
+		}
+	}
+}
+
+// make_unreadable makes a directory below root that the walk cannot read
+// and returns its path: one without permissions, or where those do not
+// stop the test, as for root, one whose path is longer than the system
+// takes.
+func make_unreadable(t *testing.T, root string) string {
+	t.Helper()
+	locked := filepath.Join(root, "locked")
+	if err := os.Mkdir(locked, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(locked, "hidden.txt"), []byte("hidden"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(locked, 0); err == nil {
+		t.Cleanup(func() { os.Chmod(locked, 0o755) })
+		if _, err := os.ReadDir(locked); err != nil {
+			return locked
+		}
+		os.Chmod(locked, 0o755)
+	}
+
+	// Made one level at a time, so no call sees the whole path.
+	name := strings.Repeat("d", 200)
+	dir, err := os.OpenRoot(locked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := locked
+	for len(path) <= 8192 {
+		if err := dir.Mkdir(name, 0o755); err != nil {
+			dir.Close()
+			t.Fatal(err)
+		}
+		next, err := dir.OpenRoot(name)
+		dir.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir = next
+		path = filepath.Join(path, name)
+	}
+	dir.Close()
+	if _, err := os.ReadDir(path); err == nil {
+		t.Skip("no directory here is unreadable to this user")
+	}
+	return path
+}
+
+func TestWalk_tree_keep_going(t *testing.T) {
+	root := t.TempDir()
+	write_files(t, root, map[string]int{"ok.txt": 7, "sub/also.txt": 3})
+	make_unreadable(t, root)
+
+	node, err := Walk_tree(root, Walk_options{Keep_going: true})
+	var partial *Partial_error
+	if !errors.As(err, &partial) {
+		t.Fatalf("Walk_tree with Keep_going: got %v, want a *Partial_error", err)
+	}
+	if len(partial.Skipped) == 0 || partial.Cause != nil {
+		t.Errorf("Partial_error = %+v, want skipped entries and no cause", partial)
+	}
+	for _, skipped := range partial.Skipped {
+		if !strings.HasPrefix(skipped.Path, filepath.Join(root, "locked")) || skipped.Err == nil || skipped.Error == "" {
+			t.Errorf("skipped %+v, want an entry below locked with its error", skipped)
+		}
+	}
+	if node == nil || node.Size < 10 {
+		t.Errorf("Walk_tree with Keep_going = %+v, want the readable files counted", node)
+	}
+
+	node, err = Walk_tree(root, Walk_options{})
+	if err == nil || errors.As(err, &partial) || node != nil {
+		t.Errorf("strict Walk_tree = %v, %v; want no tree and the error", node, err)
+	}
+}
+
+func TestWalk_tree_context_cancel(t *testing.T) {
+	root := t.TempDir()
+	write_files(t, root, map[string]int{"a/one.txt": 1, "b/two.txt": 2})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	node, err := Walk_tree_context(ctx, root, Walk_options{})
+	var partial *Partial_error
+	if !errors.As(err, &partial) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Walk_tree_context after cancel: got %v, want a *Partial_error caused by context.Canceled", err)
+	}
+	if node == nil || node.Size != 0 {
+		t.Errorf("Walk_tree_context after cancel = %+v, want an empty tree", node)
+	}
+}